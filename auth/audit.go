@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	stdlog "log"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/micro/go-os/event"
+	"github.com/micro/go-os/log"
+
+	"golang.org/x/net/context"
+)
+
+type logAudit struct {
+	l log.Logger
+}
+
+type eventAudit struct {
+	e     event.Event
+	exit  chan bool
+	once  sync.Once
+	queue chan *event.Record
+	// decisions dropped since the last was published
+	dropped uint64
+}
+
+func newEventAudit(e event.Event, size int) *eventAudit {
+	a := &eventAudit{
+		e:     e,
+		exit:  make(chan bool),
+		queue: make(chan *event.Record, size),
+	}
+	go a.run()
+	return a
+}
+
+// identity returns who the token belongs to. Never the token itself.
+func identity(t *Token) string {
+	if t == nil {
+		return ""
+	}
+	for _, k := range []string{"sub", "client_id", "spiffe_id"} {
+		if id, ok := t.Metadata[k]; ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// actor returns the service acting on behalf of the identity
+func actor(t *Token) string {
+	if t == nil {
+		return ""
+	}
+	return t.Metadata["act"]
+}
+
+// audit records the decision if auditing is enabled
+func audit(a Auth, t *Token, req Request, err error) {
+	opts := a.Options()
+	if opts.Audit == nil {
+		return
+	}
+
+	sample := opts.AuditSample
+	if sample == 0 {
+		sample = DefaultAuditSample
+		// unless allowed decisions aren't to be audited
+		if c := opts.Context; c != nil && c.Value(auditSampleKey{}) != nil {
+			sample = 0
+		}
+	}
+
+	// sample the allowed
+	if err == nil && rand.Float64() >= sample {
+		return
+	}
+
+	d := &Decision{
+		Allowed:   err == nil || failOpen(a, err),
+		Identity:  identity(t),
+		Actor:     actor(t),
+		Service:   req.Service(),
+		Method:    req.Method(),
+		Reason:    "allowed",
+		Timestamp: time.Now(),
+	}
+
+	if t != nil {
+		d.Scopes = t.Scopes
+	}
+
+	if err != nil {
+		d.Reason = err.Error()
+	}
+
+	if err != nil && d.Allowed {
+		d.Reason += ", failing open"
+	}
+
+	opts.Audit.Record(d)
+}
+
+func fields(d *Decision) map[string]string {
+	return map[string]string{
+		"allowed":   fmt.Sprintf("%v", d.Allowed),
+		"identity":  d.Identity,
+		"actor":     d.Actor,
+		"scopes":    strings.Join(d.Scopes, " "),
+		"service":   d.Service,
+		"method":    d.Method,
+		"reason":    d.Reason,
+		"timestamp": fmt.Sprintf("%d", d.Timestamp.Unix()),
+	}
+}
+
+func (l *logAudit) Record(d *Decision) error {
+	logger := l.l.WithFields(log.Fields(fields(d)))
+	if d.Allowed {
+		logger.Info("auth allowed")
+	} else {
+		logger.Log(log.WarnLevel, "auth denied")
+	}
+	return nil
+}
+
+func (l *logAudit) Close() error {
+	return nil
+}
+
+func (l *logAudit) String() string {
+	return "log"
+}
+
+// run publishes the queued decisions until closed
+func (e *eventAudit) run() {
+	for {
+		var r *event.Record
+
+		select {
+		case r = <-e.queue:
+		case <-e.exit:
+			return
+		}
+
+		// closed while waiting
+		select {
+		case <-e.exit:
+			return
+		default:
+		}
+
+		if n := atomic.SwapUint64(&e.dropped, 0); n > 0 {
+			stdlog.Printf("Dropped %d audit decisions, the queue was full", n)
+		}
+		if err := e.e.Publish(context.TODO(), r); err != nil {
+			stdlog.Printf("Failed to publish audit decision %v", err)
+		}
+	}
+}
+
+// Record queues the decision so as not to hold up requests
+func (e *eventAudit) Record(d *Decision) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	r := &event.Record{
+		Type:      AuditEventType,
+		Timestamp: d.Timestamp.Unix(),
+		Metadata:  fields(d),
+		Data:      string(b),
+	}
+
+	select {
+	case e.queue <- r:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+
+	return nil
+}
+
+// Close stops publishing, queued decisions are dropped
+func (e *eventAudit) Close() error {
+	e.once.Do(func() {
+		close(e.exit)
+	})
+	return nil
+}
+
+func (e *eventAudit) String() string {
+	return "event"
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/micro/go-os/event"
+
+	"golang.org/x/net/context"
+)
+
+type testAudit struct {
+	decisions []*Decision
+}
+
+func (t *testAudit) Record(d *Decision) error {
+	t.decisions = append(t.decisions, d)
+	return nil
+}
+
+func (t *testAudit) Close() error {
+	return nil
+}
+
+func (t *testAudit) String() string {
+	return "test"
+}
+
+// testEvent blocks publishing until released
+type testEvent struct {
+	event.Event
+
+	started chan bool
+	release chan bool
+
+	sync.Mutex
+	published []*event.Record
+}
+
+func (e *testEvent) Publish(ctx context.Context, r *event.Record) error {
+	e.started <- true
+	<-e.release
+
+	e.Lock()
+	defer e.Unlock()
+	e.published = append(e.published, r)
+
+	// the broker is down
+	return errors.New("unavailable")
+}
+
+func TestAudit(t *testing.T) {
+	rec := new(testAudit)
+	a := newPlatform(WithAudit(rec), AuditSample(0.000001), CacheSize(-1))
+
+	tk := &Token{
+		AccessToken: "secret",
+		Scopes:      []string{"billing"},
+		Metadata:    map[string]string{"sub": "asim"},
+	}
+	req := &testRequest{"go.micro.srv.billing", "Billing.Refund"}
+
+	// allowed decisions are sampled
+	for i := 0; i < 10; i++ {
+		audit(a, tk, req, nil)
+	}
+
+	// denials never are
+	audit(a, tk, req, ErrForbidden)
+
+	if len(rec.decisions) != 1 {
+		t.Fatalf("Expected 1 decision got %d", len(rec.decisions))
+	}
+
+	d := rec.decisions[0]
+	if d.Allowed || d.Identity != "asim" || d.Reason != ErrForbidden.Error() {
+		t.Fatalf("Unexpected decision %+v", d)
+	}
+	if d.Service != req.service || d.Method != req.method {
+		t.Fatalf("Unexpected target %s %s", d.Service, d.Method)
+	}
+}
+
+func TestAuditSample(t *testing.T) {
+	testData := []struct {
+		opts []Option
+		exp  int
+	}{
+		// every allowed decision by default
+		{nil, 11},
+		{[]Option{AuditSample(1)}, 11},
+		// only denials
+		{[]Option{AuditSample(0)}, 1},
+	}
+
+	req := &testRequest{"go.micro.srv.billing", "Billing.Refund"}
+
+	for _, d := range testData {
+		rec := new(testAudit)
+		a := newPlatform(append(d.opts, WithAudit(rec), CacheSize(-1))...)
+
+		for i := 0; i < 10; i++ {
+			audit(a, nil, req, nil)
+		}
+		audit(a, nil, req, ErrForbidden)
+
+		if len(rec.decisions) != d.exp {
+			t.Fatalf("Expected %d decisions got %d", d.exp, len(rec.decisions))
+		}
+
+		a.Close()
+	}
+}
+
+func TestEventAuditClose(t *testing.T) {
+	e := &testEvent{
+		started: make(chan bool, 10),
+		release: make(chan bool),
+	}
+	close(e.release)
+
+	ea := newEventAudit(e, 10)
+	a := newPlatform(WithAudit(ea), CacheSize(-1))
+
+	d := &Decision{Service: "go.micro.srv.billing", Timestamp: time.Now()}
+
+	ea.Record(d)
+	<-e.started
+
+	// closing the auth stops publishing
+	a.Close()
+	a.Close()
+
+	select {
+	case <-ea.exit:
+	default:
+		t.Fatal("Expected the event audit to be closed")
+	}
+
+	ea.Record(d)
+
+	select {
+	case <-e.started:
+		t.Fatal("Expected no decisions published after close")
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestEventAuditQueue(t *testing.T) {
+	e := &testEvent{
+		started: make(chan bool, 10),
+		release: make(chan bool),
+	}
+	a := newEventAudit(e, 1)
+
+	d := &Decision{Service: "go.micro.srv.billing", Timestamp: time.Now()}
+
+	// the worker is stuck publishing the first
+	a.Record(d)
+	<-e.started
+
+	// one more fits in the queue, the rest are dropped
+	for i := 0; i < 3; i++ {
+		if err := a.Record(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := atomic.LoadUint64(&a.dropped); n != 2 {
+		t.Fatalf("Expected 2 dropped got %d", n)
+	}
+
+	close(e.release)
+
+	// failed publishes don't stop the worker
+	for i := 0; i < 100; i++ {
+		e.Lock()
+		n := len(e.published)
+		e.Unlock()
+		if n == 2 {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatal("Expected 2 decisions published")
+}
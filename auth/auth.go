@@ -1,11 +1,12 @@
 package auth
 
 import (
-	"errors"
 	"time"
 
 	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/server"
+	"github.com/micro/go-os/event"
+	"github.com/micro/go-os/log"
 
 	"golang.org/x/net/context"
 )
@@ -23,6 +24,9 @@ type Auth interface {
 	Token() (*Token, error)
 	// Lookup a token
 	Introspect(ctx context.Context) (*Token, error)
+	// Exchange a token for one which acts on its behalf.
+	// The new token is limited to the scopes if given.
+	Exchange(t *Token, scopes ...string) (*Token, error)
 	// Revoke a token
 	Revoke(t *Token) error
 	// Will retrieve token from the context
@@ -34,13 +38,75 @@ type Auth interface {
 	FromHeader(map[string]string) (*Token, bool)
 	// Adds token to headers
 	NewHeader(map[string]string, *Token) map[string]string
+	// Stops refreshing tokens and any background work.
+	// Tokens already held are still returned.
+	Close() error
+	// Auth options
+	Options() Options
 	// Name
 	String() string
 }
 
+// Policy decides whether a token may access a service method.
+// Rules are checked in order and the first match wins. If no
+// rule matches access is denied.
+type Policy interface {
+	// Returns nil if the token is allowed to make the request
+	Check(t *Token, req Request) error
+	// Rules of the policy
+	Rules() []*Rule
+	// Name
+	String() string
+}
+
+// Rule matches a request on the service, method, token
+// scopes and token metadata. Service, Method and metadata
+// values may be glob patterns. Empty fields match anything.
+type Rule struct {
+	Effect  Effect
+	Service string
+	Method  string
+	// Token must have all the scopes
+	Scopes []string
+	// Token metadata must match all the values
+	Metadata map[string]string
+}
+
+type Effect int32
+
+// Audit records authorization decisions made by the wrappers
+type Audit interface {
+	Record(*Decision) error
+	// Stops any background recording
+	Close() error
+	String() string
+}
+
+// Decision is a single allow or deny
+type Decision struct {
+	Allowed bool
+	// The caller; sub, client_id or spiffe_id of the token
+	Identity string
+	// The service acting on behalf of the caller if delegated
+	Actor     string
+	Scopes    []string
+	Service   string
+	Method    string
+	Reason    string
+	Timestamp time.Time
+}
+
+// Error is an auth failure along with the
+// rpc status code the wrappers return for it
+type Error struct {
+	Code   int32
+	Detail string
+}
+
 type Option func(*Options)
 
-// Could be client or server request
+// Could be client or server request. For
+// publications the service is the topic.
 type Request interface {
 	Service() string
 	Method() string
@@ -56,10 +122,43 @@ type Token struct {
 	Metadata     map[string]string
 }
 
+const (
+	Deny  Effect = 0
+	Allow Effect = 1
+)
+
 var (
-	ErrInvalidToken = errors.New("invalid token")
+	DefaultCacheSize = 1024
+	DefaultCacheTTL  = time.Minute
+
+	// Fraction of a token's lifetime after which it's refreshed
+	DefaultRefreshRatio = 0.75
+
+	// Fraction of allowed decisions audited
+	DefaultAuditSample = 1.0
+	AuditEventType     = "auth.decision"
+	// Decisions waiting to be published by the event audit,
+	// any more are dropped
+	DefaultAuditQueue = 1024
+
+	RevokeTopic = "micro.auth.revoke"
+
+	// RFC 8693 token exchange
+	ExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	AccessTokenType   = "urn:ietf:params:oauth:token-type:access_token"
+
+	ErrNoToken      = &Error{401, "no token"}
+	ErrInvalidToken = &Error{401, "invalid token"}
+	ErrExpiredToken = &Error{401, "token expired"}
+	ErrForbidden    = &Error{403, "forbidden"}
+	// The auth service could not be reached
+	ErrUnavailable = &Error{503, "auth unavailable"}
 )
 
+func (e *Error) Error() string {
+	return e.Detail
+}
+
 func ClientWrapper(a Auth) client.Wrapper {
 	return func(c client.Client) client.Client {
 		return &clientWrapper{c, a}
@@ -72,6 +171,30 @@ func HandlerWrapper(a Auth) server.HandlerWrapper {
 	}
 }
 
+func SubscriberWrapper(a Auth) server.SubscriberWrapper {
+	return func(s server.SubscriberFunc) server.SubscriberFunc {
+		return subscriberWrapper(s, a)
+	}
+}
+
 func NewAuth(opts ...Option) Auth {
 	return newPlatform(opts...)
 }
+
+// NewLogAudit records decisions as log messages.
+// Denials are logged at warn level.
+func NewLogAudit(l log.Logger) Audit {
+	return &logAudit{l}
+}
+
+// NewEventAudit publishes decisions as event records. They're
+// queued and published in the background, dropping decisions
+// when the queue is full rather than holding up requests.
+// Publishing stops when the Auth using it is closed.
+func NewEventAudit(e event.Event) Audit {
+	return newEventAudit(e, DefaultAuditQueue)
+}
+
+func NewPolicy(rules ...*Rule) Policy {
+	return newPolicy(rules...)
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cache is a bounded lru of introspected tokens
+type cache struct {
+	size int
+	ttl  time.Duration
+
+	sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key    string
+	token  *Token
+	expiry time.Time
+}
+
+func newCache(size int, ttl time.Duration) *cache {
+	return &cache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *cache) Get(key string) (*Token, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	en := e.Value.(*entry)
+
+	// stale
+	if time.Now().After(en.expiry) {
+		c.ll.Remove(e)
+		delete(c.items, key)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(e)
+
+	// hand back a copy so no one messes with ours
+	t := *en.token
+	return &t, true
+}
+
+func (c *cache) Put(t *Token) {
+	c.Set(t.AccessToken, t)
+}
+
+// Set caches the token under a key other than its access token
+func (c *cache) Set(key string, t *Token) {
+	// expire at ttl or the token expiry, whichever is first
+	expiry := time.Now().Add(c.ttl)
+	if t.ExpiresAt.Before(expiry) {
+		expiry = t.ExpiresAt
+	}
+
+	cp := *t
+	en := &entry{key, &cp, expiry}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value = en
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(en)
+
+	// evict the least recently used
+	for c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*entry).key)
+	}
+}
+
+func (c *cache) Del(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// DelPrefix deletes every token with a key starting with prefix
+func (c *cache) DelPrefix(prefix string) {
+	c.Lock()
+	defer c.Unlock()
+
+	for key, e := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.ll.Remove(e)
+			delete(c.items, key)
+		}
+	}
+}
+
+func (c *cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := newCache(2, time.Minute)
+	exp := time.Now().Add(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		c.Put(&Token{AccessToken: fmt.Sprintf("token-%d", i), ExpiresAt: exp})
+	}
+
+	if c.Len() != 2 {
+		t.Fatalf("Expected 2 tokens got %d", c.Len())
+	}
+
+	// least recently used is gone
+	if _, ok := c.Get("token-0"); ok {
+		t.Fatal("Expected token-0 to be evicted")
+	}
+
+	if tk, ok := c.Get("token-2"); !ok || tk.AccessToken != "token-2" {
+		t.Fatalf("Expected token-2 got %v", tk)
+	}
+
+	c.Del("token-2")
+	if _, ok := c.Get("token-2"); ok {
+		t.Fatal("Expected token-2 to be deleted")
+	}
+
+	// never cached beyond token expiry
+	c.Put(&Token{AccessToken: "expired", ExpiresAt: time.Now().Add(-time.Second)})
+	if _, ok := c.Get("expired"); ok {
+		t.Fatal("Expected expired token to miss")
+	}
+}
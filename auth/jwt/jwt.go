@@ -0,0 +1,326 @@
+// Package jwt is an auth implementation which verifies signed
+// JWT access tokens locally rather than introspecting them with
+// the auth service. Tokens are still retrieved and revoked using
+// the default platform implementation.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-os/auth"
+
+	"golang.org/x/net/context"
+)
+
+type jwt struct {
+	// platform for retrieving and revoking tokens
+	auth.Auth
+
+	opts     auth.Options
+	issuer   string
+	audience string
+	keys     *keySet
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+var (
+	DefaultRefreshInterval = time.Hour
+	// MinRefreshInterval limits refetching the JWKS for unknown key ids
+	MinRefreshInterval = time.Second * 30
+	// FetchTimeout limits each request for the JWKS
+	FetchTimeout = time.Second * 10
+	// FailureBackoff is the wait before refetching a JWKS which
+	// failed. It doubles with each failure up to five minutes.
+	FailureBackoff = time.Second
+	// Leeway allowed for clock skew when checking exp and nbf
+	Leeway = time.Second * 30
+
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+
+	hashes = map[string]crypto.Hash{
+		"256": crypto.SHA256,
+		"384": crypto.SHA384,
+		"512": crypto.SHA512,
+	}
+)
+
+func newJWT(opts ...auth.Option) auth.Auth {
+	var options auth.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var url string
+	interval := DefaultRefreshInterval
+	static := map[string]crypto.PublicKey{}
+
+	j := &jwt{
+		// nothing to introspect so no need for the cache
+		Auth: auth.NewAuth(append(opts, auth.CacheSize(-1))...),
+		opts: options,
+	}
+
+	if c := options.Context; c != nil {
+		if k, ok := c.Value(keysKey{}).(map[string]crypto.PublicKey); ok {
+			static = k
+		}
+		if u, ok := c.Value(jwksKey{}).(string); ok {
+			url = u
+		}
+		if i, ok := c.Value(refreshKey{}).(time.Duration); ok && i > 0 {
+			interval = i
+		}
+		if i, ok := c.Value(issuerKey{}).(string); ok {
+			j.issuer = i
+		}
+		if a, ok := c.Value(audienceKey{}).(string); ok {
+			j.audience = a
+		}
+	}
+
+	j.keys = newKeySet(url, interval, static)
+	return j
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(strings.NewReader(string(b)))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// verify checks the signature of the signing input with the key
+func verify(alg string, key crypto.PublicKey, input, sig []byte) error {
+	if len(alg) != 5 {
+		return ErrUnsupportedAlg
+	}
+
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return ErrUnsupportedAlg
+	}
+
+	h := hash.New()
+	h.Write(input)
+	sum := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(pub, hash, sum, sig, nil)
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, sum, sig)
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, sum, r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	// no none or hmac here
+	return ErrUnsupportedAlg
+}
+
+func unix(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func hasAudience(v interface{}, aud string) bool {
+	switch a := v.(type) {
+	case string:
+		return a == aud
+	case []interface{}:
+		for _, s := range a {
+			if s == aud {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parse verifies the token and maps its claims onto an auth.Token
+func (j *jwt) parse(token string) (*auth.Token, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, auth.ErrInvalidToken
+	}
+
+	var hd header
+	if err := decodeSegment(parts[0], &hd); err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+
+	key, err := j.keys.Get(hd.Kid)
+	if err == ErrUnknownKey {
+		return nil, err
+	}
+	// couldn't fetch the key set
+	if err != nil {
+		return nil, auth.ErrUnavailable
+	}
+
+	if err := verify(hd.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+
+	now := time.Now()
+
+	exp, ok := unix(claims["exp"])
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	if now.After(exp.Add(Leeway)) {
+		return nil, auth.ErrExpiredToken
+	}
+
+	if nbf, ok := unix(claims["nbf"]); ok && now.Add(Leeway).Before(nbf) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	if len(j.issuer) > 0 && claims["iss"] != j.issuer {
+		return nil, auth.ErrInvalidToken
+	}
+
+	if len(j.audience) > 0 && !hasAudience(claims["aud"], j.audience) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	t := &auth.Token{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresAt:   exp,
+		Metadata:    make(map[string]string),
+	}
+
+	for k, v := range claims {
+		switch k {
+		case "exp":
+			continue
+		// space delimited oauth2 scopes
+		case "scope":
+			if s, ok := v.(string); ok {
+				t.Scopes = append(t.Scopes, strings.Fields(s)...)
+			}
+			continue
+		case "scp", "scopes":
+			if s, ok := v.([]interface{}); ok {
+				for _, scope := range s {
+					t.Scopes = append(t.Scopes, fmt.Sprintf("%v", scope))
+				}
+			}
+			continue
+		// rfc 8693 actor of a delegated token
+		case "act":
+			if a, ok := v.(map[string]interface{}); ok {
+				if sub, ok := a["sub"].(string); ok {
+					t.Metadata["act"] = sub
+				}
+			}
+			continue
+		}
+
+		switch val := v.(type) {
+		case string:
+			t.Metadata[k] = val
+		case json.Number, bool:
+			t.Metadata[k] = fmt.Sprintf("%v", val)
+		}
+	}
+
+	return t, nil
+}
+
+func (j *jwt) Authorized(ctx context.Context, req auth.Request) (*auth.Token, error) {
+	t, err := j.Introspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if j.opts.Policy == nil {
+		return t, nil
+	}
+	if err := j.opts.Policy.Check(t, req); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (j *jwt) Introspect(ctx context.Context) (*auth.Token, error) {
+	t, ok := j.FromContext(ctx)
+	if !ok {
+		md, kk := metadata.FromContext(ctx)
+		if !kk {
+			return nil, auth.ErrNoToken
+		}
+		t, ok = j.FromHeader(md)
+		if !ok {
+			return nil, auth.ErrNoToken
+		}
+	}
+	return j.parse(t.AccessToken)
+}
+
+func (j *jwt) Options() auth.Options {
+	return j.opts
+}
+
+func (j *jwt) String() string {
+	return "jwt"
+}
+
+func NewAuth(opts ...auth.Option) auth.Auth {
+	return newJWT(opts...)
+}
@@ -0,0 +1,297 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/server"
+	"github.com/micro/go-os/auth"
+
+	"golang.org/x/net/context"
+)
+
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signRSA(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := encode(map[string]string{"alg": "RS256", "kid": kid}) + "." + encode(claims)
+	sum := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signEC(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := encode(map[string]string{"alg": "ES256", "kid": kid}) + "." + encode(claims)
+	sum := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	copy(sig[32-len(r.Bytes()):32], r.Bytes())
+	copy(sig[64-len(s.Bytes()):], s.Bytes())
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+type testRequest struct{}
+
+func (r *testRequest) Service() string      { return "go.micro.srv.foo" }
+func (r *testRequest) Method() string       { return "Foo.Bar" }
+func (r *testRequest) ContentType() string  { return "application/json" }
+func (r *testRequest) Request() interface{} { return nil }
+func (r *testRequest) Stream() bool         { return false }
+
+func introspect(a auth.Auth, token string) (*auth.Token, error) {
+	ctx := a.NewContext(context.TODO(), &auth.Token{AccessToken: token, TokenType: "Bearer"})
+	return a.Introspect(ctx)
+}
+
+func TestIntrospect(t *testing.T) {
+	rkey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ekey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuth(
+		PublicKey("rsa", &rkey.PublicKey),
+		PublicKey("ec", &ekey.PublicKey),
+		Issuer("go.micro.srv.auth"),
+	)
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	claims := map[string]interface{}{
+		"iss":   "go.micro.srv.auth",
+		"sub":   "asim",
+		"exp":   exp,
+		"scope": "read write",
+	}
+
+	for _, token := range []string{
+		signRSA(t, rkey, "rsa", claims),
+		signEC(t, ekey, "ec", claims),
+	} {
+		tk, err := introspect(a, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tk.ExpiresAt.Unix() != exp {
+			t.Fatalf("Expected expiry %d got %d", exp, tk.ExpiresAt.Unix())
+		}
+		if len(tk.Scopes) != 2 || tk.Scopes[0] != "read" || tk.Scopes[1] != "write" {
+			t.Fatalf("Unexpected scopes %v", tk.Scopes)
+		}
+		if tk.Metadata["sub"] != "asim" {
+			t.Fatalf("Expected sub asim got %s", tk.Metadata["sub"])
+		}
+	}
+
+	// bad tokens
+	expired := map[string]interface{}{"iss": "go.micro.srv.auth", "exp": time.Now().Add(-time.Hour).Unix()}
+	issuer := map[string]interface{}{"iss": "evil", "exp": exp}
+
+	for _, token := range []string{
+		signRSA(t, rkey, "rsa", expired),
+		signRSA(t, rkey, "rsa", issuer),
+		signRSA(t, rkey, "unknown", claims),
+		signRSA(t, rkey, "ec", claims),
+		encode(map[string]string{"alg": "none"}) + "." + encode(claims) + ".",
+	} {
+		if _, err := introspect(a, token); err == nil {
+			t.Fatalf("Expected error for token %s", token)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	var mtx sync.Mutex
+	var keys []*rsa.PrivateKey
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		var set []map[string]string
+		for i, k := range keys {
+			set = append(set, map[string]string{
+				"kty": "RSA",
+				"kid": fmt.Sprintf("key-%d", i),
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": set})
+	}))
+	defer srv.Close()
+
+	rotate := func() *rsa.PrivateKey {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mtx.Lock()
+		keys = append(keys, key)
+		mtx.Unlock()
+		return key
+	}
+
+	defer func(d time.Duration) { MinRefreshInterval = d }(MinRefreshInterval)
+	MinRefreshInterval = 0
+
+	a := NewAuth(JWKS(srv.URL))
+	claims := map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}
+
+	key := rotate()
+	if _, err := introspect(a, signRSA(t, key, "key-0", claims)); err != nil {
+		t.Fatal(err)
+	}
+
+	// new key is picked up on first sight of its kid
+	key = rotate()
+	if _, err := introspect(a, signRSA(t, key, "key-1", claims)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeysUnavailable(t *testing.T) {
+	var mtx sync.Mutex
+	var requests int
+	block := make(chan bool)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		requests++
+		n := requests
+		mtx.Unlock()
+
+		// the first request hangs
+		if n == 1 {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	defer func(d time.Duration) { FetchTimeout = d }(FetchTimeout)
+	FetchTimeout = time.Millisecond * 100
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuth(JWKS(srv.URL))
+	token := signRSA(t, key, "key-0", map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()})
+
+	start := time.Now()
+	if _, err := introspect(a, token); err != auth.ErrUnavailable {
+		t.Fatalf("Expected unavailable got %v", err)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected the fetch to time out got %v", d)
+	}
+
+	// failures back off rather than fetching per request
+	for i := 0; i < 10; i++ {
+		if _, err := introspect(a, token); err != auth.ErrUnavailable {
+			t.Fatalf("Expected unavailable got %v", err)
+		}
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if requests != 1 {
+		t.Fatalf("Expected 1 request got %d", requests)
+	}
+}
+
+func TestKeysDownUnknownKid(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mtx sync.Mutex
+	down := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if down {
+			w.WriteHeader(500)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-0",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer srv.Close()
+
+	defer func(d time.Duration) { MinRefreshInterval = d }(MinRefreshInterval)
+	MinRefreshInterval = 0
+
+	a := NewAuth(JWKS(srv.URL), auth.FailOpen(true))
+	claims := map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}
+
+	if _, err := introspect(a, signRSA(t, key, "key-0", claims)); err != nil {
+		t.Fatal(err)
+	}
+
+	mtx.Lock()
+	down = true
+	mtx.Unlock()
+
+	// a forged token must not fail open
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := introspect(a, signRSA(t, forged, "made-up", claims)); err != ErrUnknownKey {
+			t.Fatalf("Expected unknown key got %v", err)
+		}
+	}
+
+	// through the wrapper as well
+	fn := auth.HandlerWrapper(a)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return nil
+	})
+	ctx := a.NewContext(context.TODO(), &auth.Token{AccessToken: signRSA(t, forged, "made-up", claims), TokenType: "Bearer"})
+	if err := fn(ctx, &testRequest{}, nil); err == nil {
+		t.Fatal("Expected forged token to be rejected")
+	}
+}
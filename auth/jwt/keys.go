@@ -0,0 +1,280 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+)
+
+// keySet holds static keys and keys retrieved from a JWKS url
+type keySet struct {
+	url      string
+	interval time.Duration
+	static   map[string]crypto.PublicKey
+	client   *http.Client
+
+	sync.RWMutex
+	keys    map[string]crypto.PublicKey
+	updated time.Time
+
+	// closed when the fetch in flight is done
+	fetching chan bool
+	// last fetch and the consecutive failures
+	attempted time.Time
+	failures  int
+	err       error
+}
+
+var maxFailureBackoff = time.Minute * 5
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newKeySet(url string, interval time.Duration, static map[string]crypto.PublicKey) *keySet {
+	return &keySet{
+		url:      url,
+		interval: interval,
+		static:   static,
+		client:   &http.Client{Timeout: FetchTimeout},
+		keys:     make(map[string]crypto.PublicKey),
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// fetch retrieves the JWKS
+func (k *keySet) fetch() (map[string]crypto.PublicKey, error) {
+	rsp, err := k.client.Get(k.url)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to fetch keys: %s", rsp.Status)
+	}
+
+	var set struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+
+	if err := json.NewDecoder(rsp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+
+	for _, key := range set.Keys {
+		// only interested in signing keys
+		if len(key.Use) > 0 && key.Use != "sig" {
+			continue
+		}
+		pub, err := key.publicKey()
+		if err != nil {
+			// skip what we don't understand
+			continue
+		}
+		keys[key.Kid] = pub
+	}
+
+	return keys, nil
+}
+
+// backoff is the wait before fetching again after failures
+func (k *keySet) backoff() time.Duration {
+	d := FailureBackoff
+	for i := 1; i < k.failures && d < maxFailureBackoff; i++ {
+		d *= 2
+	}
+	if d > maxFailureBackoff {
+		d = maxFailureBackoff
+	}
+	return d
+}
+
+// refresh fetches the JWKS if the keys are older than age. Only
+// one fetch is in flight, others wait for it without holding a
+// lock. After a failure the last error is returned until the
+// backoff has passed.
+func (k *keySet) refresh(age time.Duration) error {
+	k.Lock()
+
+	// someone else beat us to it
+	if time.Since(k.updated) < age {
+		k.Unlock()
+		return nil
+	}
+
+	if ch := k.fetching; ch != nil {
+		k.Unlock()
+		<-ch
+		k.RLock()
+		defer k.RUnlock()
+		return k.err
+	}
+
+	if k.failures > 0 && time.Since(k.attempted) < k.backoff() {
+		err := k.err
+		k.Unlock()
+		return err
+	}
+
+	ch := make(chan bool)
+	k.fetching = ch
+	k.attempted = time.Now()
+	k.Unlock()
+
+	keys, err := k.fetch()
+
+	k.Lock()
+	if err != nil {
+		k.failures++
+	} else {
+		k.keys = keys
+		k.updated = time.Now()
+		k.failures = 0
+	}
+	k.err = err
+	k.fetching = nil
+	k.Unlock()
+
+	close(ch)
+
+	return err
+}
+
+func (k *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if key, ok := k.static[kid]; ok {
+		return key, true
+	}
+
+	k.RLock()
+	defer k.RUnlock()
+
+	key, ok := k.keys[kid]
+	if ok {
+		return key, true
+	}
+
+	// no kid and a single key? that's the one
+	if len(kid) == 0 && len(k.keys)+len(k.static) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+		for _, key := range k.static {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+// Get returns the key for kid refreshing the
+// JWKS if it's stale or the kid is not known.
+func (k *keySet) Get(kid string) (crypto.PublicKey, error) {
+	if len(k.url) == 0 {
+		if key, ok := k.lookup(kid); ok {
+			return key, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	// periodic refresh for rotation
+	err := k.refresh(k.interval)
+
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+
+	// the key may have just been rotated in but
+	// don't hammer the endpoint for garbage kids
+	if err == nil {
+		err = k.refresh(MinRefreshInterval)
+	}
+
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+
+	// we have keys and it's not one of them, an unreachable
+	// JWKS mustn't let a made up kid through when failing open
+	if err != nil && !k.loaded() {
+		return nil, err
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// loaded is true if there are static keys or the JWKS has ever loaded
+func (k *keySet) loaded() bool {
+	if len(k.static) > 0 {
+		return true
+	}
+	k.RLock()
+	defer k.RUnlock()
+	return !k.updated.IsZero()
+}
@@ -0,0 +1,70 @@
+package jwt
+
+import (
+	"crypto"
+	"time"
+
+	"github.com/micro/go-os/auth"
+
+	"golang.org/x/net/context"
+)
+
+type keysKey struct{}
+type jwksKey struct{}
+type refreshKey struct{}
+type issuerKey struct{}
+type audienceKey struct{}
+
+func setOption(o *auth.Options, k, v interface{}) {
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	o.Context = context.WithValue(o.Context, k, v)
+}
+
+// PublicKey adds a static key used to verify tokens signed
+// with the given key id. Supports *rsa.PublicKey and *ecdsa.PublicKey.
+func PublicKey(kid string, key crypto.PublicKey) auth.Option {
+	return func(o *auth.Options) {
+		keys := map[string]crypto.PublicKey{}
+		if o.Context != nil {
+			if k, ok := o.Context.Value(keysKey{}).(map[string]crypto.PublicKey); ok {
+				for id, v := range k {
+					keys[id] = v
+				}
+			}
+		}
+		keys[kid] = key
+		setOption(o, keysKey{}, keys)
+	}
+}
+
+// JWKS is the url of a JSON Web Key Set used to verify tokens.
+// The key set is refreshed periodically and when a token is
+// signed with an unknown key id.
+func JWKS(url string) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, jwksKey{}, url)
+	}
+}
+
+// RefreshInterval is how often the JWKS is refetched.
+func RefreshInterval(d time.Duration) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, refreshKey{}, d)
+	}
+}
+
+// Issuer requires tokens to have a matching iss claim.
+func Issuer(iss string) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, issuerKey{}, iss)
+	}
+}
+
+// Audience requires tokens to include aud in the aud claim.
+func Audience(aud string) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, audienceKey{}, aud)
+	}
+}
@@ -0,0 +1,285 @@
+// Package memory is an in memory auth for testing. Tokens are
+// minted locally and every call to Authorized is recorded so
+// services can be tested without the auth service.
+package memory
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-os/auth"
+	"github.com/pborman/uuid"
+
+	"golang.org/x/net/context"
+)
+
+type Auth struct {
+	opts auth.Options
+
+	sync.Mutex
+	// our own token
+	token *auth.Token
+	// minted tokens by access token
+	tokens map[string]*auth.Token
+	calls  []*Call
+	// returned by everything if set
+	err error
+}
+
+// Call is a recorded call to Authorized
+type Call struct {
+	Service string
+	Method  string
+	// The token introspected, nil if there wasn't one
+	Token *auth.Token
+	Err   error
+}
+
+type tokenKey struct{}
+
+var (
+	// Lifetime of our own token
+	DefaultTokenTTL = time.Hour
+)
+
+func NewAuth(opts ...auth.Option) *Auth {
+	var options auth.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	a := &Auth{
+		opts:   options,
+		tokens: make(map[string]*auth.Token),
+	}
+
+	a.token = a.Mint(DefaultTokenTTL, nil, map[string]string{
+		"client_id": options.Id,
+	})
+
+	return a
+}
+
+func copyToken(t *auth.Token) *auth.Token {
+	ct := *t
+	ct.Scopes = append([]string{}, t.Scopes...)
+	ct.Metadata = make(map[string]string)
+	for k, v := range t.Metadata {
+		ct.Metadata[k] = v
+	}
+	return &ct
+}
+
+// Mint creates a token which expires after ttl. A ttl
+// in the past can be used to create an expired token.
+func (a *Auth) Mint(ttl time.Duration, scopes []string, md map[string]string) *auth.Token {
+	t := &auth.Token{
+		AccessToken:  uuid.NewUUID().String(),
+		RefreshToken: uuid.NewUUID().String(),
+		TokenType:    "bearer",
+		ExpiresAt:    time.Now().Add(ttl),
+		Scopes:       scopes,
+		Metadata:     md,
+	}
+
+	t = copyToken(t)
+
+	a.Lock()
+	a.tokens[t.AccessToken] = t
+	a.Unlock()
+
+	return copyToken(t)
+}
+
+// Fail makes every call return the error e.g. auth.ErrUnavailable
+// to simulate an outage. Set to nil to recover.
+func (a *Auth) Fail(err error) {
+	a.Lock()
+	a.err = err
+	a.Unlock()
+}
+
+// Calls returns the calls made to Authorized in order
+func (a *Auth) Calls() []*Call {
+	a.Lock()
+	defer a.Unlock()
+	return append([]*Call{}, a.calls...)
+}
+
+// Reset forgets the recorded calls
+func (a *Auth) Reset() {
+	a.Lock()
+	a.calls = nil
+	a.Unlock()
+}
+
+func (a *Auth) record(req auth.Request, t *auth.Token, err error) {
+	a.Lock()
+	a.calls = append(a.calls, &Call{
+		Service: req.Service(),
+		Method:  req.Method(),
+		Token:   t,
+		Err:     err,
+	})
+	a.Unlock()
+}
+
+func (a *Auth) Authorized(ctx context.Context, req auth.Request) (*auth.Token, error) {
+	t, err := a.Introspect(ctx)
+	if err != nil {
+		a.record(req, nil, err)
+		return nil, err
+	}
+
+	if t.ExpiresAt.Before(time.Now()) {
+		err = auth.ErrExpiredToken
+	} else if a.opts.Policy != nil {
+		err = a.opts.Policy.Check(t, req)
+	}
+
+	a.record(req, t, err)
+
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (a *Auth) Token() (*auth.Token, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.err != nil {
+		return nil, a.err
+	}
+	return copyToken(a.token), nil
+}
+
+func (a *Auth) Introspect(ctx context.Context) (*auth.Token, error) {
+	t, ok := a.FromContext(ctx)
+	if !ok {
+		md, kk := metadata.FromContext(ctx)
+		if !kk {
+			return nil, auth.ErrNoToken
+		}
+		t, ok = a.FromHeader(md)
+		if !ok {
+			return nil, auth.ErrNoToken
+		}
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	it, ok := a.tokens[t.AccessToken]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	return copyToken(it), nil
+}
+
+// Exchange mints a token for the subject of t with our client id as
+// the actor, limited to the scopes t has if any are requested
+func (a *Auth) Exchange(t *auth.Token, scopes ...string) (*auth.Token, error) {
+	a.Lock()
+	it, ok := a.tokens[t.AccessToken]
+	err := a.err
+	a.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+
+	granted := it.Scopes
+	if len(scopes) > 0 {
+		granted = nil
+		for _, s := range scopes {
+			for _, has := range it.Scopes {
+				if s == has {
+					granted = append(granted, s)
+					break
+				}
+			}
+		}
+	}
+
+	md := make(map[string]string)
+	for k, v := range it.Metadata {
+		md[k] = v
+	}
+	md["act"] = a.opts.Id
+
+	return a.Mint(it.ExpiresAt.Sub(time.Now()), granted, md), nil
+}
+
+func (a *Auth) Revoke(t *auth.Token) error {
+	a.Lock()
+	defer a.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+	delete(a.tokens, t.AccessToken)
+	return nil
+}
+
+func (a *Auth) FromContext(ctx context.Context) (*auth.Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(*auth.Token)
+	return t, ok
+}
+
+func (a *Auth) NewContext(ctx context.Context, t *auth.Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+func (a *Auth) FromHeader(hd map[string]string) (*auth.Token, bool) {
+	var t string
+	var ok bool
+
+	for _, key := range []string{"authorization", "Authorization"} {
+		t, ok = hd[key]
+		if ok {
+			break
+		}
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	parts := strings.Split(t, " ")
+	if len(parts) != 2 {
+		return nil, false
+	}
+	return &auth.Token{
+		AccessToken: parts[1],
+		TokenType:   parts[0],
+	}, true
+}
+
+func (a *Auth) NewHeader(hd map[string]string, t *auth.Token) map[string]string {
+	hd["authorization"] = t.TokenType + " " + t.AccessToken
+	return hd
+}
+
+func (a *Auth) Close() error {
+	if a.opts.Audit != nil {
+		a.opts.Audit.Close()
+	}
+	return nil
+}
+
+func (a *Auth) Options() auth.Options {
+	return a.opts
+}
+
+func (a *Auth) String() string {
+	return "memory"
+}
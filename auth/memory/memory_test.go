@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/server"
+	"github.com/micro/go-os/auth"
+
+	"golang.org/x/net/context"
+)
+
+type testRequest struct {
+	service string
+	method  string
+}
+
+func (r *testRequest) Service() string {
+	return r.service
+}
+
+func (r *testRequest) Method() string {
+	return r.method
+}
+
+func (r *testRequest) ContentType() string {
+	return "application/json"
+}
+
+func (r *testRequest) Request() interface{} {
+	return nil
+}
+
+func (r *testRequest) Stream() bool {
+	return false
+}
+
+func TestHandlerWrapper(t *testing.T) {
+	a := NewAuth(auth.WithPolicy(auth.NewPolicy(&auth.Rule{
+		Effect: auth.Allow,
+		Scopes: []string{"read"},
+	})))
+
+	reader := a.Mint(time.Hour, []string{"read"}, map[string]string{"sub": "asim"})
+	writer := a.Mint(time.Hour, []string{"write"}, nil)
+	expired := a.Mint(-time.Hour, []string{"read"}, nil)
+	revoked := a.Mint(time.Hour, []string{"read"}, nil)
+
+	if err := a.Revoke(revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		token *auth.Token
+		code  int32
+	}{
+		{reader, 0},
+		{writer, 403},
+		{expired, 401},
+		{revoked, 401},
+		{nil, 401},
+	}
+
+	fn := auth.HandlerWrapper(a)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return nil
+	})
+
+	req := &testRequest{"go.micro.srv.foo", "Foo.Bar"}
+
+	for _, d := range testData {
+		ctx := context.TODO()
+		if d.token != nil {
+			ctx = a.NewContext(ctx, d.token)
+		}
+
+		err := fn(ctx, req, nil)
+		if d.code == 0 {
+			if err != nil {
+				t.Fatalf("expected to be authorized got %v", err)
+			}
+			continue
+		}
+
+		if e, ok := err.(*errors.Error); !ok || e.Code != d.code {
+			t.Fatalf("expected %d got %v", d.code, err)
+		}
+	}
+
+	calls := a.Calls()
+	// revoked and missing tokens fail introspection first
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 calls to Authorized got %d", len(calls))
+	}
+
+	if c := calls[0]; c.Service != "go.micro.srv.foo" || c.Method != "Foo.Bar" || c.Err != nil || c.Token.Metadata["sub"] != "asim" {
+		t.Fatalf("unexpected call %+v", c)
+	}
+
+	if c := calls[1]; c.Err != auth.ErrForbidden {
+		t.Fatalf("expected forbidden got %v", c.Err)
+	}
+
+	if c := calls[2]; c.Err != auth.ErrExpiredToken {
+		t.Fatalf("expected expired got %v", c.Err)
+	}
+
+	a.Reset()
+	if len(a.Calls()) != 0 {
+		t.Fatal("expected calls to be reset")
+	}
+}
+
+func TestFail(t *testing.T) {
+	a := NewAuth(auth.FailOpen(true))
+	tk := a.Mint(time.Hour, nil, nil)
+
+	var called bool
+	fn := auth.HandlerWrapper(a)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		called = true
+		return nil
+	})
+
+	a.Fail(auth.ErrUnavailable)
+
+	if err := fn(a.NewContext(context.TODO(), tk), &testRequest{}, nil); err != nil || !called {
+		t.Fatalf("expected to fail open got %v", err)
+	}
+
+	if _, err := a.Token(); err != auth.ErrUnavailable {
+		t.Fatalf("expected unavailable got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"time"
+
 	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/server"
+	"github.com/micro/go-os/metrics"
 	"golang.org/x/net/context"
 )
 
@@ -9,16 +13,55 @@ type Options struct {
 	Id     string
 	Secret string
 	Client client.Client
+	// Server used to subscribe to revocations. Without
+	// one only local revocations clear the cache.
+	Server server.Server
+	// Policy applied by Authorized
+	Policy Policy
+	// Number of introspected tokens to cache.
+	// A negative size disables the cache.
+	CacheSize int
+	// Max time an introspected token is cached
+	CacheTTL time.Duration
+	// Records cache hits and misses
+	Metrics metrics.Metrics
+	// Fraction of the token lifetime after
+	// which it's refreshed in the background
+	RefreshRatio float64
+	// Records authorization decisions
+	Audit Audit
+	// Fraction of allowed decisions to audit.
+	// Denials are always audited. Zero means the
+	// DefaultAuditSample unless set with AuditSample.
+	AuditSample float64
+	// Let requests through when the auth service
+	// is unavailable. The default is to reject them.
+	FailOpen bool
+	// Exchange the callers token in the client
+	// wrapper rather than forwarding it
+	Delegate bool
+	// Scopes requested for exchanged tokens
+	DelegateScopes []string
 	// Used for alternative options
 	Context context.Context
 }
 
+type auditSampleKey struct{}
+
 func Client(c client.Client) Option {
 	return func(o *Options) {
 		o.Client = c
 	}
 }
 
+// Server subscribes to revocations published by other
+// instances so their tokens are dropped from the cache
+func Server(s server.Server) Option {
+	return func(o *Options) {
+		o.Server = s
+	}
+}
+
 func Id(id string) Option {
 	return func(o *Options) {
 		o.Id = id
@@ -30,3 +73,81 @@ func Secret(s string) Option {
 		o.Secret = s
 	}
 }
+
+// WithPolicy sets the policy checked by Authorized.
+// Without a policy any valid token is authorized.
+func WithPolicy(p Policy) Option {
+	return func(o *Options) {
+		o.Policy = p
+	}
+}
+
+// CacheSize is the number of introspected tokens to cache.
+// Set to a negative value to disable caching.
+func CacheSize(i int) Option {
+	return func(o *Options) {
+		o.CacheSize = i
+	}
+}
+
+// CacheTTL is the max time an introspected token is cached.
+// Tokens are never cached beyond their expiry.
+func CacheTTL(t time.Duration) Option {
+	return func(o *Options) {
+		o.CacheTTL = t
+	}
+}
+
+// Metrics records introspection cache hits and misses
+func Metrics(m metrics.Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
+
+// RefreshRatio is the fraction of a token's lifetime
+// after which it's refreshed in the background e.g 0.75
+func RefreshRatio(r float64) Option {
+	return func(o *Options) {
+		o.RefreshRatio = r
+	}
+}
+
+// WithAudit records every authorization decision made by
+// the client, handler and subscriber wrappers
+func WithAudit(a Audit) Option {
+	return func(o *Options) {
+		o.Audit = a
+	}
+}
+
+// AuditSample is the fraction of allowed decisions to
+// audit e.g 0.1, zero audits none. Denials are always audited.
+func AuditSample(s float64) Option {
+	return func(o *Options) {
+		o.AuditSample = s
+		// so zero isn't taken as unset
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, auditSampleKey{}, true)
+	}
+}
+
+// FailOpen lets requests through the wrappers when the auth
+// service can't be reached rather than returning a 503
+func FailOpen(b bool) Option {
+	return func(o *Options) {
+		o.FailOpen = b
+	}
+}
+
+// Delegate makes the client wrapper exchange the callers token for
+// one carrying both the caller and this service as the actor. The
+// exchanged token is limited to the scopes if given.
+func Delegate(scopes ...string) Option {
+	return func(o *Options) {
+		o.Delegate = true
+		o.DelegateScopes = scopes
+	}
+}
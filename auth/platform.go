@@ -1,14 +1,21 @@
 package auth
 
 import (
+	"log"
+	"math"
+	"math/rand"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
 	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-micro/server"
+	"github.com/micro/go-os/metrics"
 
 	oauth2 "github.com/micro/auth-srv/proto/oauth2"
+	proto "github.com/micro/go-os/auth/proto"
 	"golang.org/x/net/context"
 )
 
@@ -17,10 +24,28 @@ type platform struct {
 	opts Options
 	c    oauth2.Oauth2Client
 
+	// introspection cache, nil if disabled
+	cache *cache
+	// exchanged tokens by subject and scopes
+	exchanged *cache
+
+	once sync.Once
+	// closes exit once
+	closeOnce sync.Once
+	// shortest wait between refreshes
+	minRefresh time.Duration
+
 	sync.Mutex
 	t *Token
+	// when the token was issued
+	issued time.Time
 }
 
+var (
+	minRefresh = time.Second
+	maxBackoff = time.Minute
+)
+
 type tokenKey struct{}
 
 func newPlatform(opts ...Option) Auth {
@@ -32,68 +57,245 @@ func newPlatform(opts ...Option) Auth {
 		options.Client = client.DefaultClient
 	}
 
+	if options.CacheSize == 0 {
+		options.CacheSize = DefaultCacheSize
+	}
+
+	if options.CacheTTL == time.Duration(0) {
+		options.CacheTTL = DefaultCacheTTL
+	}
+
+	if options.RefreshRatio <= 0 || options.RefreshRatio > 1 {
+		options.RefreshRatio = DefaultRefreshRatio
+	}
+
 	p := &platform{
-		exit: make(chan bool),
-		opts: options,
-		c:    oauth2.NewOauth2Client("go.micro.srv.auth", options.Client),
+		exit:       make(chan bool),
+		opts:       options,
+		c:          oauth2.NewOauth2Client("go.micro.srv.auth", options.Client),
+		minRefresh: minRefresh,
+	}
+
+	if options.CacheSize > 0 {
+		p.cache = newCache(options.CacheSize, options.CacheTTL)
+		p.exchanged = newCache(options.CacheSize, options.CacheTTL)
+	}
+
+	// drop tokens revoked elsewhere
+	if options.Server != nil && p.cache != nil {
+		err := options.Server.Subscribe(
+			options.Server.NewSubscriber(
+				RevokeTopic,
+				p.revoked,
+				server.InternalSubscriber(true),
+			),
+		)
+		if err != nil {
+			log.Printf("Failed to subscribe to revocations %v", err)
+		}
 	}
 
 	return p
 }
 
+// invalidate drops the token and any tokens exchanged for it
+func (p *platform) invalidate(token string) {
+	if p.cache == nil {
+		return
+	}
+	p.cache.Del(token)
+	p.exchanged.DelPrefix(token + " ")
+}
+
+func (p *platform) revoked(ctx context.Context, t *proto.Token) error {
+	p.invalidate(t.AccessToken)
+	return nil
+}
+
+// rpcError maps an error from the auth service. A rejected
+// request means a bad token, anything else that we couldn't
+// reach it e.g. timeouts, unknown service.
+func rpcError(err error) error {
+	e, ok := err.(*errors.Error)
+	if !ok {
+		e = errors.Parse(err.Error())
+	}
+	if e.Code >= 400 && e.Code < 500 && e.Code != 408 {
+		return ErrInvalidToken
+	}
+	return ErrUnavailable
+}
+
+func (p *platform) record(result string) {
+	if p.opts.Metrics == nil {
+		return
+	}
+	p.opts.Metrics.Counter("auth.introspect.cache").WithFields(metrics.Fields{
+		"result": result,
+	}).Incr(1)
+}
+
 func (p *platform) Authorized(ctx context.Context, req Request) (*Token, error) {
-	// There's no policies yet. Just check if the token is valid.
 	t, err := p.Introspect(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// and just for safe keeping
 	if t.ExpiresAt.Before(time.Now()) {
-		return nil, ErrInvalidToken
+		return nil, ErrExpiredToken
+	}
+	// no policy, any valid token will do
+	if p.opts.Policy == nil {
+		return t, nil
+	}
+	if err := p.opts.Policy.Check(t, req); err != nil {
+		return nil, err
 	}
 	return t, nil
 }
 
-func (p *platform) Token() (*Token, error) {
-	p.Lock()
-	defer p.Unlock()
+// grant requests a new token from the auth service
+func (p *platform) grant(req *oauth2.TokenRequest) (*Token, error) {
+	req.ClientId = p.opts.Id
+	req.ClientSecret = p.opts.Secret
 
-	// we should have cached the token and if it hasn't expired we'll hand it back
-	if p.t != nil && len(p.t.AccessToken) > 0 && !p.t.ExpiresAt.Before(time.Now()) {
-		return p.t, nil
-	}
-
-	var grantType, refreshToken string
-
-	// if its nil, ask for new token
-	if p.t == nil {
-		grantType = "client_credentials"
-	} else {
-		// ask for refresh token
-		grantType = "refresh_token"
-		refreshToken = p.t.RefreshToken
-	}
-
-	rsp, err := p.c.Token(context.TODO(), &oauth2.TokenRequest{
-		GrantType:    grantType,
-		ClientId:     p.opts.Id,
-		ClientSecret: p.opts.Secret,
-		RefreshToken: refreshToken,
-	})
-
-	// error? just return invalid token
+	rsp, err := p.c.Token(context.TODO(), req)
 	if err != nil {
-		return nil, ErrInvalidToken
+		return nil, err
 	}
 
-	// save token for reuse
-	p.t = &Token{
+	return &Token{
 		AccessToken:  rsp.Token.AccessToken,
 		RefreshToken: rsp.Token.RefreshToken,
 		TokenType:    rsp.Token.TokenType,
 		ExpiresAt:    time.Unix(rsp.Token.ExpiresAt, 0),
 		Scopes:       rsp.Token.Scopes,
 		Metadata:     rsp.Token.Metadata,
+	}, nil
+}
+
+// refresh retrieves and saves a new token. It tries a
+// refresh grant first and falls back to client credentials.
+// Should be called with the lock held.
+func (p *platform) refresh() error {
+	var t *Token
+	var err error
+
+	if p.t != nil && len(p.t.RefreshToken) > 0 {
+		t, err = p.grant(&oauth2.TokenRequest{
+			GrantType:    "refresh_token",
+			RefreshToken: p.t.RefreshToken,
+		})
+	}
+
+	// no token or the refresh failed, ask for a new one
+	if t == nil {
+		t, err = p.grant(&oauth2.TokenRequest{
+			GrantType: "client_credentials",
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// save token for reuse
+	p.t = t
+	p.issued = time.Now()
+
+	// start refreshing in the background
+	p.once.Do(func() {
+		go p.run()
+	})
+
+	return nil
+}
+
+// expires is false for tokens issued without an expiry
+func expires(t *Token) bool {
+	return t.ExpiresAt.Unix() > 0
+}
+
+// next returns when the current token should be refreshed
+func (p *platform) next() time.Duration {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.t == nil {
+		return 0
+	}
+
+	// nothing to refresh, check again later
+	if !expires(p.t) {
+		return maxBackoff
+	}
+
+	lifetime := p.t.ExpiresAt.Sub(p.issued)
+	return p.issued.Add(time.Duration(float64(lifetime) * p.opts.RefreshRatio)).Sub(time.Now())
+}
+
+// backoff with jitter in the range [d/2, 3d/2)
+func backoff(attempts int) time.Duration {
+	d := time.Second * time.Duration(math.Pow(2, float64(attempts)))
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// run refreshes the token before it expires
+func (p *platform) run() {
+	var attempts int
+
+	for {
+		wait := p.next()
+		if attempts > 0 {
+			wait = backoff(attempts)
+		}
+
+		// don't spin on short lived tokens
+		if wait < p.minRefresh {
+			wait = p.minRefresh
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-p.exit:
+			return
+		}
+
+		p.Lock()
+		if p.t != nil && !expires(p.t) {
+			p.Unlock()
+			continue
+		}
+		err := p.refresh()
+		// an expired token is no better than none so back off
+		if err == nil && expires(p.t) && !p.t.ExpiresAt.After(time.Now()) {
+			err = ErrExpiredToken
+		}
+		p.Unlock()
+
+		if err != nil {
+			attempts++
+			continue
+		}
+
+		attempts = 0
+	}
+}
+
+func (p *platform) Token() (*Token, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	// we should have cached the token and if it hasn't expired we'll hand it back
+	if p.t != nil && len(p.t.AccessToken) > 0 && (!expires(p.t) || !p.t.ExpiresAt.Before(time.Now())) {
+		return p.t, nil
+	}
+
+	if err := p.refresh(); err != nil {
+		return nil, rpcError(err)
 	}
 
 	return p.t, nil
@@ -104,19 +306,27 @@ func (p *platform) Introspect(ctx context.Context) (*Token, error) {
 	if !ok {
 		md, kk := metadata.FromContext(ctx)
 		if !kk {
-			return nil, ErrInvalidToken
+			return nil, ErrNoToken
 		}
 		t, ok = p.FromHeader(md)
 		if !ok {
-			return nil, ErrInvalidToken
+			return nil, ErrNoToken
 		}
 	}
 
+	if p.cache != nil {
+		if ct, ok := p.cache.Get(t.AccessToken); ok {
+			p.record("hit")
+			return ct, nil
+		}
+		p.record("miss")
+	}
+
 	rsp, err := p.c.Introspect(context.TODO(), &oauth2.IntrospectRequest{
 		AccessToken: t.AccessToken,
 	})
 	if err != nil {
-		return nil, err
+		return nil, rpcError(err)
 	}
 
 	// if its not active just err?
@@ -124,22 +334,76 @@ func (p *platform) Introspect(ctx context.Context) (*Token, error) {
 		return nil, ErrInvalidToken
 	}
 
-	return &Token{
+	it := &Token{
 		AccessToken:  rsp.Token.AccessToken,
 		RefreshToken: rsp.Token.RefreshToken,
 		TokenType:    rsp.Token.TokenType,
 		ExpiresAt:    time.Unix(rsp.Token.ExpiresAt, 0),
 		Scopes:       rsp.Token.Scopes,
 		Metadata:     rsp.Token.Metadata,
-	}, nil
+	}
+
+	if p.cache != nil {
+		p.cache.Put(it)
+	}
+
+	return it, nil
+}
+
+// Exchange requests a token for the subject with our own token as
+// the actor. The auth service sets the sub and act of the new token.
+func (p *platform) Exchange(t *Token, scopes ...string) (*Token, error) {
+	key := t.AccessToken + " " + strings.Join(scopes, " ")
+
+	if p.exchanged != nil {
+		if et, ok := p.exchanged.Get(key); ok {
+			return et, nil
+		}
+	}
+
+	actor, err := p.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	et, err := p.grant(&oauth2.TokenRequest{
+		GrantType: ExchangeGrantType,
+		Scopes:    scopes,
+		Metadata: map[string]string{
+			"subject_token":      t.AccessToken,
+			"subject_token_type": AccessTokenType,
+			"actor_token":        actor.AccessToken,
+			"actor_token_type":   AccessTokenType,
+		},
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	if p.exchanged != nil {
+		p.exchanged.Set(key, et)
+	}
+
+	return et, nil
 }
 
 func (p *platform) Revoke(t *Token) error {
+	p.invalidate(t.AccessToken)
+
 	_, err := p.c.Revoke(context.TODO(), &oauth2.RevokeRequest{
 		AccessToken:  t.AccessToken,
 		RefreshToken: t.RefreshToken,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	// let everyone else know
+	pub := p.opts.Client.NewPublication(RevokeTopic, &proto.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+	})
+	return p.opts.Client.Publish(context.TODO(), pub)
 }
 
 func (p *platform) FromContext(ctx context.Context) (*Token, bool) {
@@ -184,6 +448,20 @@ func (p *platform) NewHeader(hd map[string]string, t *Token) map[string]string {
 	return hd
 }
 
+func (p *platform) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.exit)
+		if p.opts.Audit != nil {
+			p.opts.Audit.Close()
+		}
+	})
+	return nil
+}
+
+func (p *platform) Options() Options {
+	return p.opts
+}
+
 func (p *platform) String() string {
 	return "platform"
 }
@@ -0,0 +1,359 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/server"
+
+	oauth2 "github.com/micro/auth-srv/proto/oauth2"
+	proto "github.com/micro/go-os/auth/proto"
+	"golang.org/x/net/context"
+)
+
+type testOauth2 struct {
+	sync.Mutex
+	grants []string
+	// last request for each grant type
+	reqs map[string]*oauth2.TokenRequest
+	// fail refresh grants
+	badRefresh bool
+	// issue tokens with this expiry rather than in 2 seconds
+	expiresAt *int64
+	// revoked access tokens
+	revoked []string
+}
+
+// testClient records publications
+type testClient struct {
+	client.Client
+
+	sync.Mutex
+	published []interface{}
+}
+
+type testPublication struct {
+	topic string
+	msg   interface{}
+}
+
+func (p *testPublication) Topic() string        { return p.topic }
+func (p *testPublication) Message() interface{} { return p.msg }
+func (p *testPublication) ContentType() string  { return "application/octet-stream" }
+
+func (c *testClient) NewPublication(topic string, msg interface{}) client.Publication {
+	return &testPublication{topic, msg}
+}
+
+func (c *testClient) Publish(ctx context.Context, p client.Publication, opts ...client.PublishOption) error {
+	c.Lock()
+	defer c.Unlock()
+	c.published = append(c.published, p.Message())
+	return nil
+}
+
+// testServer records subscriptions
+type testServer struct {
+	server.Server
+	topics []string
+}
+
+type testSubscriber struct {
+	server.Subscriber
+	topic string
+}
+
+func (s *testSubscriber) Topic() string { return s.topic }
+
+func (s *testServer) NewSubscriber(topic string, h interface{}, opts ...server.SubscriberOption) server.Subscriber {
+	return &testSubscriber{topic: topic}
+}
+
+func (s *testServer) Subscribe(sb server.Subscriber) error {
+	s.topics = append(s.topics, sb.Topic())
+	return nil
+}
+
+func (o *testOauth2) Token(ctx context.Context, req *oauth2.TokenRequest, opts ...client.CallOption) (*oauth2.TokenResponse, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.grants = append(o.grants, req.GrantType)
+	if o.reqs == nil {
+		o.reqs = make(map[string]*oauth2.TokenRequest)
+	}
+	o.reqs[req.GrantType] = req
+
+	if req.GrantType == "refresh_token" && o.badRefresh {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	expiresAt := time.Now().Add(time.Second * 2).Unix()
+	if o.expiresAt != nil {
+		expiresAt = *o.expiresAt
+	}
+
+	return &oauth2.TokenResponse{
+		Token: &oauth2.Token{
+			AccessToken:  fmt.Sprintf("access-%d", len(o.grants)),
+			RefreshToken: fmt.Sprintf("refresh-%d", len(o.grants)),
+			TokenType:    "bearer",
+			ExpiresAt:    expiresAt,
+		},
+	}, nil
+}
+
+func (o *testOauth2) Introspect(ctx context.Context, req *oauth2.IntrospectRequest, opts ...client.CallOption) (*oauth2.IntrospectResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (o *testOauth2) Revoke(ctx context.Context, req *oauth2.RevokeRequest, opts ...client.CallOption) (*oauth2.RevokeResponse, error) {
+	o.Lock()
+	defer o.Unlock()
+	o.revoked = append(o.revoked, req.AccessToken)
+	return &oauth2.RevokeResponse{}, nil
+}
+
+func (o *testOauth2) Grants() []string {
+	o.Lock()
+	defer o.Unlock()
+	return append([]string{}, o.grants...)
+}
+
+func TestTokenRefresh(t *testing.T) {
+	testData := []struct {
+		badRefresh bool
+		grants     []string
+	}{
+		{false, []string{"client_credentials", "refresh_token"}},
+		{true, []string{"client_credentials", "refresh_token", "client_credentials"}},
+	}
+
+	for _, d := range testData {
+		o := &testOauth2{badRefresh: d.badRefresh}
+
+		p := newPlatform(RefreshRatio(0.1), CacheSize(-1)).(*platform)
+		p.c = o
+		p.minRefresh = time.Millisecond * 10
+
+		tk, err := p.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// refreshed well before expiry
+		time.Sleep(time.Millisecond * 500)
+		p.Close()
+
+		grants := o.Grants()
+		if len(grants) < len(d.grants) {
+			t.Fatalf("Expected grants %v got %v", d.grants, grants)
+		}
+		for i, g := range d.grants {
+			if grants[i] != g {
+				t.Fatalf("Expected grants %v got %v", d.grants, grants)
+			}
+		}
+
+		nt, err := p.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nt.AccessToken == tk.AccessToken {
+			t.Fatal("Expected token to be refreshed")
+		}
+	}
+}
+
+func TestTokenNoRefresh(t *testing.T) {
+	never := int64(0)
+	expired := time.Now().Add(-time.Hour).Unix()
+
+	testData := []struct {
+		expiresAt *int64
+		grants    int
+	}{
+		// no expiry, nothing to refresh
+		{&never, 1},
+		// already expired, one retry then backs off for a second or more
+		{&expired, 2},
+	}
+
+	for _, d := range testData {
+		o := &testOauth2{expiresAt: d.expiresAt}
+
+		p := newPlatform(CacheSize(-1)).(*platform)
+		p.c = o
+		p.minRefresh = time.Millisecond * 10
+
+		if _, err := p.Token(); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond * 200)
+		p.Close()
+
+		if grants := o.Grants(); len(grants) != d.grants {
+			t.Fatalf("Expected %d grants got %v", d.grants, grants)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	o := &testOauth2{}
+
+	p := newPlatform().(*platform)
+	p.c = o
+
+	user := &Token{AccessToken: "user", TokenType: "bearer"}
+
+	et, err := p.Exchange(user, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := o.reqs[ExchangeGrantType]
+	if req == nil {
+		t.Fatal("expected a token exchange grant")
+	}
+
+	actor, _ := p.Token()
+
+	if req.Metadata["subject_token"] != "user" || req.Metadata["actor_token"] != actor.AccessToken {
+		t.Fatalf("unexpected subject and actor %v", req.Metadata)
+	}
+
+	if len(req.Scopes) != 1 || req.Scopes[0] != "read" {
+		t.Fatalf("unexpected scopes %v", req.Scopes)
+	}
+
+	// should be cached
+	ct, err := p.Exchange(user, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ct.AccessToken != et.AccessToken {
+		t.Fatalf("expected cached token %s got %s", et.AccessToken, ct.AccessToken)
+	}
+
+	// different scopes is a new exchange
+	if ct, _ := p.Exchange(user, "write"); ct.AccessToken == et.AccessToken {
+		t.Fatal("expected a new token for different scopes")
+	}
+
+	p.Close()
+}
+
+func TestRevoke(t *testing.T) {
+	o := &testOauth2{}
+	c := &testClient{}
+
+	p := newPlatform(Client(c)).(*platform)
+	p.c = o
+	defer p.Close()
+
+	user := &Token{
+		AccessToken:  "user",
+		RefreshToken: "refresh",
+		TokenType:    "bearer",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	p.cache.Put(user)
+	if _, ok := p.cache.Get("user"); !ok {
+		t.Fatal("Expected token to be cached")
+	}
+
+	et, err := p.Exchange(user, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Revoke(user); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(o.revoked) != 1 || o.revoked[0] != "user" {
+		t.Fatalf("Expected user to be revoked got %v", o.revoked)
+	}
+
+	if len(c.published) != 1 {
+		t.Fatalf("Expected 1 revocation published got %d", len(c.published))
+	}
+	if tk, ok := c.published[0].(*proto.Token); !ok || tk.AccessToken != "user" {
+		t.Fatalf("Unexpected revocation %v", c.published[0])
+	}
+
+	if _, ok := p.cache.Get("user"); ok {
+		t.Fatal("Expected revoked token to be dropped from the cache")
+	}
+
+	// exchanged tokens go with the subject
+	nt, err := p.Exchange(user, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nt.AccessToken == et.AccessToken {
+		t.Fatal("Expected a new exchange after revocation")
+	}
+}
+
+func TestRevoked(t *testing.T) {
+	o := &testOauth2{}
+
+	// nothing to invalidate without a cache
+	s := &testServer{}
+	newPlatform(CacheSize(-1), Server(s)).Close()
+	if len(s.topics) != 0 {
+		t.Fatalf("Expected no subscription without a cache got %v", s.topics)
+	}
+
+	s = &testServer{}
+	p := newPlatform(Server(s)).(*platform)
+	p.c = o
+	defer p.Close()
+
+	if len(s.topics) != 1 || s.topics[0] != RevokeTopic {
+		t.Fatalf("Expected subscription to %s got %v", RevokeTopic, s.topics)
+	}
+
+	user := &Token{AccessToken: "user", TokenType: "bearer", ExpiresAt: time.Now().Add(time.Hour)}
+	other := &Token{AccessToken: "user2", TokenType: "bearer"}
+
+	p.cache.Put(user)
+	if _, ok := p.cache.Get("user"); !ok {
+		t.Fatal("Expected token to be cached")
+	}
+
+	et, err := p.Exchange(user, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ot, err := p.Exchange(other, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// revoked by another instance
+	if err := p.revoked(context.TODO(), &proto.Token{AccessToken: "user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.cache.Get("user"); ok {
+		t.Fatal("Expected revoked token to be dropped from the cache")
+	}
+
+	if nt, _ := p.Exchange(user, "read"); nt.AccessToken == et.AccessToken {
+		t.Fatal("Expected exchanged token to be dropped")
+	}
+
+	// other subjects are untouched
+	if nt, _ := p.Exchange(other, "read"); nt.AccessToken != ot.AccessToken {
+		t.Fatalf("Expected cached token %s got %s", ot.AccessToken, nt.AccessToken)
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"path"
+)
+
+type policy struct {
+	rules []*Rule
+}
+
+func newPolicy(rules ...*Rule) Policy {
+	return &policy{rules}
+}
+
+// match a glob pattern, empty matches everything
+func match(pattern, s string) bool {
+	if len(pattern) == 0 || pattern == "*" {
+		return true
+	}
+	ok, err := path.Match(pattern, s)
+	if err != nil {
+		return false
+	}
+	return ok
+}
+
+func (r *Rule) match(t *Token, req Request) bool {
+	if !match(r.Service, req.Service()) {
+		return false
+	}
+
+	if !match(r.Method, req.Method()) {
+		return false
+	}
+
+	// token must have every scope
+SCOPES:
+	for _, scope := range r.Scopes {
+		for _, s := range t.Scopes {
+			if s == scope {
+				continue SCOPES
+			}
+		}
+		return false
+	}
+
+	// and every bit of metadata
+	for k, v := range r.Metadata {
+		mv, ok := t.Metadata[k]
+		if !ok || !match(v, mv) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *policy) Check(t *Token, req Request) error {
+	if t == nil {
+		return ErrInvalidToken
+	}
+
+	for _, rule := range p.rules {
+		if !rule.match(t, req) {
+			continue
+		}
+		if rule.Effect == Allow {
+			return nil
+		}
+		return ErrForbidden
+	}
+
+	// default deny
+	return ErrForbidden
+}
+
+func (p *policy) Rules() []*Rule {
+	return p.rules
+}
+
+func (p *policy) String() string {
+	return "policy"
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"testing"
+)
+
+type testRequest struct {
+	service string
+	method  string
+}
+
+func (r *testRequest) Service() string {
+	return r.service
+}
+
+func (r *testRequest) Method() string {
+	return r.method
+}
+
+func TestPolicy(t *testing.T) {
+	p := NewPolicy(
+		&Rule{
+			Effect:  Deny,
+			Service: "go.micro.srv.billing",
+			Method:  "Billing.Refund",
+			Metadata: map[string]string{
+				"type": "user",
+			},
+		},
+		&Rule{
+			Effect:  Allow,
+			Service: "go.micro.srv.billing",
+			Method:  "Billing.*",
+			Scopes:  []string{"billing"},
+		},
+		&Rule{
+			Effect:  Allow,
+			Service: "go.micro.srv.*",
+			Method:  "*.Read",
+		},
+	)
+
+	user := &Token{
+		Scopes:   []string{"billing"},
+		Metadata: map[string]string{"type": "user"},
+	}
+
+	service := &Token{
+		Scopes:   []string{"billing", "admin"},
+		Metadata: map[string]string{"type": "service"},
+	}
+
+	testData := []struct {
+		token   *Token
+		service string
+		method  string
+		err     error
+	}{
+		{user, "go.micro.srv.billing", "Billing.Refund", ErrForbidden},
+		{service, "go.micro.srv.billing", "Billing.Refund", nil},
+		{user, "go.micro.srv.billing", "Billing.Charge", nil},
+		{&Token{}, "go.micro.srv.billing", "Billing.Charge", ErrForbidden},
+		{&Token{}, "go.micro.srv.user", "User.Read", nil},
+		{user, "go.micro.srv.user", "User.Delete", ErrForbidden},
+		{nil, "go.micro.srv.user", "User.Read", ErrInvalidToken},
+	}
+
+	for _, d := range testData {
+		err := p.Check(d.token, &testRequest{d.service, d.method})
+		if err != d.err {
+			t.Fatalf("Expected %v got %v for %s %s", d.err, err, d.service, d.method)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package spiffe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"golang.org/x/net/context"
+)
+
+type peerKey struct{}
+
+// PeerFromContext returns the peer certificate chain set by the server
+func PeerFromContext(ctx context.Context) ([]*x509.Certificate, bool) {
+	c, ok := ctx.Value(peerKey{}).([]*x509.Certificate)
+	return c, ok && len(c) > 0
+}
+
+// NewPeerContext sets the verified peer certificate chain,
+// leaf first, as presented in tls.ConnectionState.PeerCertificates
+func NewPeerContext(ctx context.Context, certs []*x509.Certificate) context.Context {
+	return context.WithValue(ctx, peerKey{}, certs)
+}
+
+// NewTLSContext sets the peer certificate chain of a tls connection
+// e.g. from http.Request.TLS or a custom transport. The chain is
+// verified against the CA bundle when the request is introspected.
+func NewTLSContext(ctx context.Context, state tls.ConnectionState) context.Context {
+	return NewPeerContext(ctx, state.PeerCertificates)
+}
@@ -0,0 +1,57 @@
+package spiffe
+
+import (
+	"time"
+
+	"github.com/micro/go-os/auth"
+
+	"golang.org/x/net/context"
+)
+
+type certFileKey struct{}
+type keyFileKey struct{}
+type caFileKey struct{}
+type trustDomainKey struct{}
+type reloadKey struct{}
+
+func setOption(o *auth.Options, k, v interface{}) {
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	o.Context = context.WithValue(o.Context, k, v)
+}
+
+// CertFile is the PEM encoded certificate holding our SPIFFE ID
+func CertFile(path string) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, certFileKey{}, path)
+	}
+}
+
+// KeyFile is the PEM encoded private key for the certificate
+func KeyFile(path string) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, keyFileKey{}, path)
+	}
+}
+
+// CAFile is the PEM encoded CA bundle peers are verified against
+func CAFile(path string) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, caFileKey{}, path)
+	}
+}
+
+// TrustDomain only accepts peers from the trust domain e.g example.org
+func TrustDomain(td string) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, trustDomainKey{}, td)
+	}
+}
+
+// ReloadInterval is how often the files are checked for rotation
+func ReloadInterval(d time.Duration) auth.Option {
+	return func(o *auth.Options) {
+		setOption(o, reloadKey{}, d)
+	}
+}
@@ -0,0 +1,476 @@
+// Package spiffe is an auth implementation which identifies services
+// by the SPIFFE ID in their X.509 certificate. Peers are verified
+// against a local CA bundle and the certificate, key and bundle are
+// reloaded from disk when rotated.
+//
+// Peers are only identified by the certificate chain in the context.
+// The go-micro transports don't set it so the server must, using
+// NewPeerContext or NewTLSContext, before the auth HandlerWrapper
+// runs. Without it every request is rejected as unauthorized.
+package spiffe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-os/auth"
+
+	"golang.org/x/net/context"
+)
+
+type spiffe struct {
+	exit chan bool
+	opts auth.Options
+	once sync.Once
+
+	certFile    string
+	keyFile     string
+	caFile      string
+	trustDomain string
+	interval    time.Duration
+
+	sync.RWMutex
+	// the last load error
+	err     error
+	cert    *tls.Certificate
+	leaf    *x509.Certificate
+	roots   *x509.CertPool
+	modTime time.Time
+}
+
+type tokenKey struct{}
+
+var (
+	DefaultReloadInterval = time.Minute
+
+	TokenType = "spiffe"
+
+	ErrNoIdentity  = errors.New("no spiffe id")
+	ErrUnsupported = errors.New("not supported")
+)
+
+func newSpiffe(opts ...auth.Option) *spiffe {
+	var options auth.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	s := &spiffe{
+		exit:     make(chan bool),
+		opts:     options,
+		interval: DefaultReloadInterval,
+	}
+
+	if c := options.Context; c != nil {
+		if v, ok := c.Value(certFileKey{}).(string); ok {
+			s.certFile = v
+		}
+		if v, ok := c.Value(keyFileKey{}).(string); ok {
+			s.keyFile = v
+		}
+		if v, ok := c.Value(caFileKey{}).(string); ok {
+			s.caFile = v
+		}
+		if v, ok := c.Value(trustDomainKey{}).(string); ok {
+			s.trustDomain = v
+		}
+		if v, ok := c.Value(reloadKey{}).(time.Duration); ok && v > 0 {
+			s.interval = v
+		}
+	}
+
+	// errors are returned on use until the files are fixed
+	s.load()
+
+	go s.run()
+	return s
+}
+
+// ID returns the SPIFFE ID from the URI SAN of the certificate
+func ID(cert *x509.Certificate) (*url.URL, error) {
+	var id *url.URL
+
+	for _, uri := range cert.URIs {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+		// there can only be one
+		if id != nil {
+			return nil, errors.New("multiple spiffe ids")
+		}
+		id = uri
+	}
+
+	if id == nil || len(id.Host) == 0 {
+		return nil, ErrNoIdentity
+	}
+
+	return id, nil
+}
+
+// modified returns the latest mod time of the files
+func (s *spiffe) modified() time.Time {
+	var t time.Time
+	for _, f := range []string{s.certFile, s.keyFile, s.caFile} {
+		if len(f) == 0 {
+			continue
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t
+}
+
+// load reads the certificate, key and ca bundle from disk
+func (s *spiffe) load() error {
+	modTime := s.modified()
+
+	err := s.read()
+
+	s.Lock()
+	defer s.Unlock()
+
+	// keep what we have if the new files are bad, they may be
+	// half way through being written so try again next time
+	if err != nil {
+		if s.roots == nil {
+			s.err = err
+		}
+		return err
+	}
+
+	s.modTime = modTime
+	return nil
+}
+
+func (s *spiffe) read() error {
+	var cert *tls.Certificate
+	var leaf *x509.Certificate
+
+	if len(s.certFile) > 0 {
+		c, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return err
+		}
+		leaf, err = x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			return err
+		}
+		if _, err := ID(leaf); err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	roots := x509.NewCertPool()
+
+	if len(s.caFile) > 0 {
+		b, err := ioutil.ReadFile(s.caFile)
+		if err != nil {
+			return err
+		}
+
+		var found bool
+
+		for len(b) > 0 {
+			var block *pem.Block
+			block, b = pem.Decode(b)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			ca, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return err
+			}
+			roots.AddCert(ca)
+			found = true
+		}
+
+		if !found {
+			return fmt.Errorf("no certificates found in %s", s.caFile)
+		}
+	}
+
+	s.Lock()
+	s.err = nil
+	s.cert = cert
+	s.leaf = leaf
+	s.roots = roots
+	s.Unlock()
+
+	return nil
+}
+
+// run reloads the files when they're rotated
+func (s *spiffe) run() {
+	t := time.NewTicker(s.interval)
+
+	for {
+		select {
+		case <-t.C:
+			s.RLock()
+			modTime := s.modTime
+			s.RUnlock()
+
+			if !s.modified().After(modTime) {
+				continue
+			}
+
+			s.load()
+		case <-s.exit:
+			t.Stop()
+			return
+		}
+	}
+}
+
+// verify checks the chain against the ca bundle and returns a token
+func (s *spiffe) verify(certs []*x509.Certificate) (*auth.Token, error) {
+	if len(certs) == 0 {
+		return nil, auth.ErrNoToken
+	}
+
+	s.RLock()
+	roots, err := s.roots, s.err
+	s.RUnlock()
+
+	if roots == nil {
+		return nil, err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	leaf := certs[0]
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.token(leaf)
+}
+
+// token creates a token from the certificate
+func (s *spiffe) token(cert *x509.Certificate) (*auth.Token, error) {
+	id, err := ID(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.trustDomain) > 0 && id.Host != s.trustDomain {
+		return nil, fmt.Errorf("spiffe id %s not in trust domain %s", id, s.trustDomain)
+	}
+
+	return &auth.Token{
+		AccessToken: id.String(),
+		TokenType:   TokenType,
+		ExpiresAt:   cert.NotAfter,
+		Metadata: map[string]string{
+			"spiffe_id":    id.String(),
+			"trust_domain": id.Host,
+			"path":         id.Path,
+			"subject":      cert.Subject.CommonName,
+			"serial":       cert.SerialNumber.String(),
+		},
+	}, nil
+}
+
+// verifyPeer is used to verify tls connections against the current bundle
+func (s *spiffe) verifyPeer(raw [][]byte, _ [][]*x509.Certificate) error {
+	var certs []*x509.Certificate
+	for _, b := range raw {
+		c, err := x509.ParseCertificate(b)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, c)
+	}
+	_, err := s.verify(certs)
+	return err
+}
+
+func (s *spiffe) certificate() (*tls.Certificate, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.cert == nil {
+		return nil, ErrNoIdentity
+	}
+	return s.cert, nil
+}
+
+// Config returns a tls config for mutual tls which presents the
+// current certificate and verifies peers against the ca bundle.
+func (s *spiffe) Config() *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.certificate()
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.certificate()
+		},
+		ClientAuth: tls.RequireAnyClientCert,
+		// peers are identified by spiffe id rather than host name
+		// so the chain is verified by VerifyPeerCertificate
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: s.verifyPeer,
+	}
+}
+
+func (s *spiffe) Authorized(ctx context.Context, req auth.Request) (*auth.Token, error) {
+	t, err := s.Introspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if t.ExpiresAt.Before(time.Now()) {
+		return nil, auth.ErrExpiredToken
+	}
+	if s.opts.Policy == nil {
+		return t, nil
+	}
+	if err := s.opts.Policy.Check(t, req); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Token returns a token for our own identity
+func (s *spiffe) Token() (*auth.Token, error) {
+	s.RLock()
+	leaf, err := s.leaf, s.err
+	s.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if leaf == nil {
+		return nil, ErrNoIdentity
+	}
+
+	return s.token(leaf)
+}
+
+// Introspect verifies the peer certificates in the context.
+// Headers are never trusted as they can't prove identity.
+func (s *spiffe) Introspect(ctx context.Context) (*auth.Token, error) {
+	if certs, ok := PeerFromContext(ctx); ok {
+		return s.verify(certs)
+	}
+
+	// a token we already verified in this process
+	if t, ok := s.FromContext(ctx); ok && t.TokenType == TokenType {
+		return t, nil
+	}
+
+	return nil, auth.ErrNoToken
+}
+
+// Exchange is unsupported, certificates can't be delegated
+func (s *spiffe) Exchange(t *auth.Token, scopes ...string) (*auth.Token, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *spiffe) Revoke(t *auth.Token) error {
+	return ErrUnsupported
+}
+
+func (s *spiffe) FromContext(ctx context.Context) (*auth.Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(*auth.Token)
+	return t, ok
+}
+
+func (s *spiffe) NewContext(ctx context.Context, t *auth.Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+func (s *spiffe) FromHeader(hd map[string]string) (*auth.Token, bool) {
+	var t string
+	var ok bool
+
+	for _, key := range []string{"authorization", "Authorization"} {
+		t, ok = hd[key]
+		if ok {
+			break
+		}
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	parts := strings.Split(t, " ")
+	if len(parts) != 2 || parts[0] != TokenType {
+		return nil, false
+	}
+
+	return &auth.Token{
+		AccessToken: parts[1],
+		TokenType:   parts[0],
+	}, true
+}
+
+// NewHeader only advertises the identity, the
+// receiver verifies it using the tls connection
+func (s *spiffe) NewHeader(hd map[string]string, t *auth.Token) map[string]string {
+	hd["authorization"] = t.TokenType + " " + t.AccessToken
+	return hd
+}
+
+func (s *spiffe) Close() error {
+	s.once.Do(func() {
+		close(s.exit)
+		if s.opts.Audit != nil {
+			s.opts.Audit.Close()
+		}
+	})
+	return nil
+}
+
+func (s *spiffe) Options() auth.Options {
+	return s.opts
+}
+
+func (s *spiffe) String() string {
+	return "spiffe"
+}
+
+// TLSConfig returns the mutual tls config of a spiffe auth
+func TLSConfig(a auth.Auth) (*tls.Config, bool) {
+	s, ok := a.(*spiffe)
+	if !ok {
+		return nil, false
+	}
+	return s.Config(), true
+}
+
+// NewAuth returns a spiffe auth. The files are loaded on creation
+// and any error is returned when the auth is used.
+func NewAuth(opts ...auth.Option) auth.Auth {
+	return newSpiffe(opts...)
+}
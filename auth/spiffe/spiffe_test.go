@@ -0,0 +1,294 @@
+package spiffe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/server"
+	"github.com/micro/go-os/auth"
+
+	"golang.org/x/net/context"
+)
+
+type testRequest struct{}
+
+func (r *testRequest) Service() string {
+	return "go.micro.srv.billing"
+}
+
+func (r *testRequest) Method() string {
+	return "Billing.Charge"
+}
+
+type testServerRequest struct {
+	testRequest
+}
+
+func (r *testServerRequest) ContentType() string {
+	return "application/json"
+}
+
+func (r *testServerRequest) Request() interface{} {
+	return nil
+}
+
+func (r *testServerRequest) Stream() bool {
+	return false
+}
+
+func newCert(t *testing.T, id string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+
+	if len(id) > 0 {
+		u, _ := url.Parse(id)
+		tmpl.URIs = []*url.URL{u}
+	} else {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+
+	b, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, typ string, b []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeIdentity writes a ca and a certificate and key for the id
+func writeIdentity(t *testing.T, dir, id string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	ca, caKey := newCert(t, "", nil, nil)
+	cert, key := newCert(t, id, ca, caKey)
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "cert.pem"), "CERTIFICATE", cert.Raw)
+	writePEM(t, filepath.Join(dir, "key.pem"), "EC PRIVATE KEY", keyBytes)
+
+	return ca, caKey
+}
+
+func TestSpiffe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spiffe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := newCert(t, "", nil, nil)
+	cert, key := newCert(t, "spiffe://example.org/billing", ca, caKey)
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "cert.pem"), "CERTIFICATE", cert.Raw)
+	writePEM(t, filepath.Join(dir, "key.pem"), "EC PRIVATE KEY", keyBytes)
+
+	a := NewAuth(
+		CertFile(filepath.Join(dir, "cert.pem")),
+		KeyFile(filepath.Join(dir, "key.pem")),
+		CAFile(filepath.Join(dir, "ca.pem")),
+		TrustDomain("example.org"),
+		auth.WithPolicy(auth.NewPolicy(&auth.Rule{
+			Effect:   auth.Allow,
+			Metadata: map[string]string{"path": "/billing"},
+		})),
+	)
+	defer a.Close()
+
+	// our own identity
+	tk, err := a.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.AccessToken != "spiffe://example.org/billing" {
+		t.Fatalf("Unexpected id %s", tk.AccessToken)
+	}
+
+	// a trusted peer
+	ctx := NewPeerContext(context.TODO(), []*x509.Certificate{cert})
+	if _, err := a.Authorized(ctx, &testRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// denied by policy
+	other, _ := newCert(t, "spiffe://example.org/users", ca, caKey)
+	ctx = NewPeerContext(context.TODO(), []*x509.Certificate{other})
+	if _, err := a.Authorized(ctx, &testRequest{}); err != auth.ErrForbidden {
+		t.Fatalf("Expected forbidden got %v", err)
+	}
+
+	// wrong trust domain
+	foreign, _ := newCert(t, "spiffe://evil.org/billing", ca, caKey)
+	ctx = NewPeerContext(context.TODO(), []*x509.Certificate{foreign})
+	if _, err := a.Introspect(ctx); err == nil {
+		t.Fatal("Expected error for foreign trust domain")
+	}
+
+	// untrusted ca
+	evilCA, evilKey := newCert(t, "", nil, nil)
+	untrusted, _ := newCert(t, "spiffe://example.org/billing", evilCA, evilKey)
+	ctx = NewPeerContext(context.TODO(), []*x509.Certificate{untrusted})
+	if _, err := a.Introspect(ctx); err == nil {
+		t.Fatal("Expected error for untrusted certificate")
+	}
+
+	// headers are not proof of identity
+	md := a.NewHeader(map[string]string{}, tk)
+	if _, ok := a.FromHeader(md); !ok {
+		t.Fatal("Expected token from header")
+	}
+	if _, err := a.Introspect(context.TODO()); err == nil {
+		t.Fatal("Expected error without peer certificates")
+	}
+}
+
+func TestHandlerWrapper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spiffe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := writeIdentity(t, dir, "spiffe://example.org/billing")
+	peer, _ := newCert(t, "spiffe://example.org/users", ca, caKey)
+
+	a := NewAuth(
+		CertFile(filepath.Join(dir, "cert.pem")),
+		KeyFile(filepath.Join(dir, "key.pem")),
+		CAFile(filepath.Join(dir, "ca.pem")),
+	)
+	defer a.Close()
+
+	var id string
+	fn := auth.HandlerWrapper(a)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		tk, _ := a.FromContext(ctx)
+		id = tk.AccessToken
+		return nil
+	})
+
+	req := &testServerRequest{}
+
+	// the server sets the peer from the tls connection
+	ctx := NewTLSContext(context.TODO(), tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{peer},
+	})
+	if err := fn(ctx, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if id != "spiffe://example.org/users" {
+		t.Fatalf("Expected spiffe://example.org/users got %s", id)
+	}
+
+	// nothing set the peer
+	err = fn(context.TODO(), req, nil)
+	if e, ok := err.(*errors.Error); !ok || e.Code != 401 {
+		t.Fatalf("Expected 401 got %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spiffe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeIdentity(t, dir, "spiffe://example.org/billing")
+
+	a := NewAuth(
+		CertFile(filepath.Join(dir, "cert.pem")),
+		KeyFile(filepath.Join(dir, "key.pem")),
+		CAFile(filepath.Join(dir, "ca.pem")),
+		ReloadInterval(time.Millisecond*10),
+	)
+	defer a.Close()
+
+	id := func() string {
+		tk, err := a.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tk.AccessToken
+	}
+
+	files := []string{"ca.pem", "cert.pem", "key.pem"}
+	modTime := time.Now().Add(time.Hour)
+
+	touch := func() {
+		for _, f := range files {
+			if err := os.Chtimes(filepath.Join(dir, f), modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// half written, the old identity is kept
+	if err := ioutil.WriteFile(filepath.Join(dir, "cert.pem"), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch()
+
+	time.Sleep(time.Millisecond * 50)
+
+	if v := id(); v != "spiffe://example.org/billing" {
+		t.Fatalf("Expected spiffe://example.org/billing got %s", v)
+	}
+
+	// finished writing within the same mod time
+	writeIdentity(t, dir, "spiffe://example.org/rotated")
+	touch()
+
+	time.Sleep(time.Millisecond * 50)
+
+	if v := id(); v != "spiffe://example.org/rotated" {
+		t.Fatalf("Expected spiffe://example.org/rotated got %s", v)
+	}
+}
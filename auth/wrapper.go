@@ -2,6 +2,7 @@ package auth
 
 import (
 	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
 	"github.com/micro/go-micro/metadata"
 	"github.com/micro/go-micro/server"
 
@@ -13,14 +14,58 @@ type clientWrapper struct {
 	a Auth
 }
 
-func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+// publication is a Request for broker publications.
+// The topic is the service and the method is empty.
+type publication struct {
+	topic string
+}
+
+func (p *publication) Service() string {
+	return p.topic
+}
+
+func (p *publication) Method() string {
+	return ""
+}
+
+// rpcErr converts an auth error to an rpc error with
+// the matching status code. Unknown errors are a 401.
+func rpcErr(req Request, err error) error {
+	if e, ok := err.(*Error); ok {
+		return errors.New(req.Service(), e.Detail, e.Code)
+	}
+	return errors.Unauthorized(req.Service(), err.Error())
+}
+
+// failOpen returns true if the request should go ahead
+// because the auth service is down and we fail open
+func failOpen(a Auth, err error) bool {
+	return err == ErrUnavailable && a.Options().FailOpen
+}
+
+// newContext returns a context with the callers token, or our
+// own if there isn't one, set in the context and metadata.
+// When delegating the callers token is exchanged first.
+func (c *clientWrapper) newContext(ctx context.Context, req Request) (context.Context, error) {
 	// retrieve token if one exists
 	t, err := c.a.Introspect(ctx)
-	if err != nil {
+	if opts := c.a.Options(); err == nil && opts.Delegate {
+		// act on behalf of the caller
+		t, err = c.a.Exchange(t, opts.DelegateScopes...)
+		if failOpen(c.a, err) {
+			return ctx, nil
+		}
+		if err != nil {
+			return nil, rpcErr(req, err)
+		}
+	} else if err != nil {
 		// no? ok let's try make the call as ourself
 		t, err = c.a.Token()
+		if failOpen(c.a, err) {
+			return ctx, nil
+		}
 		if err != nil {
-			return err
+			return nil, rpcErr(req, err)
 		}
 	}
 
@@ -33,18 +78,37 @@ func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 		md = metadata.Metadata{}
 	}
 
+	// copy so we don't modify the callers metadata
+	nmd := metadata.Metadata{}
+	for k, v := range md {
+		nmd[k] = v
+	}
+
 	// set auth headers
 	for k, v := range c.a.NewHeader(map[string]string{}, t) {
-		md[k] = v
+		nmd[k] = v
 	}
 
 	// set metadata
-	newCtx = metadata.NewContext(newCtx, md)
+	newCtx = metadata.NewContext(newCtx, nmd)
 
 	// circuit break, check authorization here
-	t, err = c.a.Authorized(newCtx, req)
+	_, err = c.a.Authorized(newCtx, req)
+	audit(c.a, t, req, err)
+	if failOpen(c.a, err) {
+		return newCtx, nil
+	}
+	if err != nil {
+		return nil, rpcErr(req, err)
+	}
+
+	return newCtx, nil
+}
+
+func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	newCtx, err := c.newContext(ctx, req)
 	if err != nil {
-		return ErrInvalidToken
+		return err
 	}
 
 	// now just make a regular call down the stack
@@ -52,19 +116,44 @@ func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 	return err
 }
 
+func (c *clientWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Streamer, error) {
+	newCtx, err := c.newContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return c.Client.Stream(newCtx, req, opts...)
+}
+
+func (c *clientWrapper) Publish(ctx context.Context, p client.Publication, opts ...client.PublishOption) error {
+	newCtx, err := c.newContext(ctx, &publication{p.Topic()})
+	if err != nil {
+		return err
+	}
+	return c.Client.Publish(newCtx, p, opts...)
+}
+
 func handlerWrapper(fn server.HandlerFunc, a Auth) server.HandlerFunc {
 	return func(ctx context.Context, req server.Request, rsp interface{}) error {
 		// retrieve token
 		t, err := a.Introspect(ctx)
 		if err != nil {
-			return ErrInvalidToken
+			audit(a, nil, req, err)
+			if failOpen(a, err) {
+				return fn(ctx, req, rsp)
+			}
+			return rpcErr(req, err)
 		}
 
 		// check if authorized
-		t, err = a.Authorized(ctx, req)
+		at, err := a.Authorized(ctx, req)
+		audit(a, t, req, err)
+		if failOpen(a, err) {
+			return fn(a.NewContext(ctx, t), req, rsp)
+		}
 		if err != nil {
-			return ErrInvalidToken
+			return rpcErr(req, err)
 		}
+		t = at
 
 		// create new context with token
 		newCtx := a.NewContext(ctx, t)
@@ -73,3 +162,28 @@ func handlerWrapper(fn server.HandlerFunc, a Auth) server.HandlerFunc {
 		return err
 	}
 }
+
+func subscriberWrapper(fn server.SubscriberFunc, a Auth) server.SubscriberFunc {
+	return func(ctx context.Context, msg server.Publication) error {
+		req := &publication{msg.Topic()}
+
+		// check if authorized to receive from the topic
+		t, err := a.Authorized(ctx, req)
+		if failOpen(a, err) {
+			audit(a, nil, req, err)
+			return fn(ctx, msg)
+		}
+		if err != nil {
+			// who was it?
+			it, _ := a.Introspect(ctx)
+			audit(a, it, req, err)
+			return rpcErr(req, err)
+		}
+		audit(a, t, req, nil)
+
+		// create new context with token
+		newCtx := a.NewContext(ctx, t)
+
+		return fn(newCtx, msg)
+	}
+}
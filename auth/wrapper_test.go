@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-micro/server"
+
+	"golang.org/x/net/context"
+)
+
+type testServerRequest struct {
+	testRequest
+}
+
+// testAuth authorizes requests to allowed services
+type testAuth struct {
+	Auth
+	allowed string
+
+	// requests checked by Authorized
+	reqs []Request
+}
+
+func (r *testServerRequest) ContentType() string {
+	return "application/json"
+}
+
+func (r *testServerRequest) Request() interface{} {
+	return nil
+}
+
+func (r *testServerRequest) Stream() bool {
+	return false
+}
+
+func (a *testAuth) Token() (*Token, error) {
+	return &Token{AccessToken: "abc", TokenType: "bearer"}, nil
+}
+
+func (a *testAuth) Introspect(ctx context.Context) (*Token, error) {
+	return nil, ErrInvalidToken
+}
+
+func (a *testAuth) Authorized(ctx context.Context, req Request) (*Token, error) {
+	a.reqs = append(a.reqs, req)
+	if req.Service() != a.allowed {
+		return nil, ErrForbidden
+	}
+	return &Token{}, nil
+}
+
+func (a *testAuth) NewContext(ctx context.Context, t *Token) context.Context {
+	return ctx
+}
+
+func (a *testAuth) NewHeader(hd map[string]string, t *Token) map[string]string {
+	return hd
+}
+
+func (a *testAuth) Options() Options {
+	return Options{}
+}
+
+func TestHandlerWrapperErrors(t *testing.T) {
+	withToken := metadata.NewContext(context.TODO(), metadata.Metadata{
+		"authorization": "bearer abc",
+	})
+
+	testData := []struct {
+		ctx      context.Context
+		failOpen bool
+		code     int32
+	}{
+		// no token
+		{context.TODO(), false, 401},
+		{context.TODO(), true, 401},
+		// introspection fails
+		{withToken, false, 503},
+		{withToken, true, 0},
+	}
+
+	for _, d := range testData {
+		p := newPlatform(CacheSize(-1), FailOpen(d.failOpen)).(*platform)
+		p.c = &testOauth2{}
+
+		var called bool
+		fn := handlerWrapper(func(ctx context.Context, req server.Request, rsp interface{}) error {
+			called = true
+			return nil
+		}, p)
+
+		req := &testServerRequest{testRequest{"go.micro.srv.foo", "Foo.Bar"}}
+		err := fn(d.ctx, req, nil)
+
+		if d.code == 0 {
+			if err != nil || !called {
+				t.Fatalf("expected handler to be called, got %v", err)
+			}
+			continue
+		}
+
+		if called {
+			t.Fatal("handler should not have been called")
+		}
+
+		e, ok := err.(*errors.Error)
+		if !ok {
+			t.Fatalf("expected rpc error, got %v", err)
+		}
+		if e.Code != d.code || e.Id != "go.micro.srv.foo" {
+			t.Fatalf("expected %d from go.micro.srv.foo, got %d from %s", d.code, e.Code, e.Id)
+		}
+	}
+}
+
+func TestPublishWrapper(t *testing.T) {
+	for _, allowed := range []string{"topic.foo", "topic.bar"} {
+		a := &testAuth{allowed: allowed}
+		c := &testClient{}
+
+		err := ClientWrapper(a)(c).Publish(context.TODO(), &testPublication{"topic.foo", "hello"})
+
+		// authorized against the topic
+		if len(a.reqs) != 1 || a.reqs[0].Service() != "topic.foo" || a.reqs[0].Method() != "" {
+			t.Fatalf("Expected topic.foo to be authorized got %+v", a.reqs)
+		}
+
+		if allowed == "topic.foo" {
+			if err != nil || len(c.published) != 1 {
+				t.Fatalf("Expected publication got %v", err)
+			}
+			continue
+		}
+
+		if len(c.published) != 0 {
+			t.Fatal("Expected no publication")
+		}
+
+		e, ok := err.(*errors.Error)
+		if !ok || e.Code != 403 || e.Id != "topic.foo" {
+			t.Fatalf("Expected 403 from topic.foo got %v", err)
+		}
+	}
+}
+
+func TestSubscriberWrapper(t *testing.T) {
+	for _, allowed := range []string{"topic.foo", "topic.bar"} {
+		a := &testAuth{allowed: allowed}
+
+		var called bool
+		fn := SubscriberWrapper(a)(func(ctx context.Context, msg server.Publication) error {
+			called = true
+			return nil
+		})
+
+		err := fn(context.TODO(), &testPublication{"topic.foo", "hello"})
+
+		// authorized against the topic
+		if len(a.reqs) != 1 || a.reqs[0].Service() != "topic.foo" || a.reqs[0].Method() != "" {
+			t.Fatalf("Expected topic.foo to be authorized got %+v", a.reqs)
+		}
+
+		if allowed == "topic.foo" {
+			if err != nil || !called {
+				t.Fatalf("Expected subscriber to be called got %v", err)
+			}
+			continue
+		}
+
+		if called {
+			t.Fatal("subscriber should not have been called")
+		}
+
+		e, ok := err.(*errors.Error)
+		if !ok || e.Code != 403 || e.Id != "topic.foo" {
+			t.Fatalf("Expected 403 from topic.foo got %v", err)
+		}
+	}
+}
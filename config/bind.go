@@ -0,0 +1,213 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type binding struct {
+	typ    reflect.Type
+	fields []*field
+	w      Watcher
+	value  atomic.Value
+
+	sync.Mutex
+	fns []func(interface{})
+}
+
+// lookup returns the value at the path in m
+func lookup(m interface{}, path []string) interface{} {
+	for _, p := range path {
+		mm, ok := m.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = mm[p]
+	}
+	return m
+}
+
+// assign sets the field to the config value
+func assign(fv reflect.Value, v interface{}) error {
+	if fv.Type() == durationType {
+		s, ok := v.(string)
+		if !ok {
+			return errors.New("not a duration")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch k := fv.Kind(); {
+	case k == reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return errors.New("not a string")
+		}
+		fv.SetString(s)
+		return nil
+	case k == reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return errors.New("not a bool")
+		}
+		fv.SetBool(b)
+		return nil
+	case k >= reflect.Int && k <= reflect.Int64:
+		f, ok := v.(float64)
+		if !ok {
+			return errors.New("not a number")
+		}
+		fv.SetInt(int64(f))
+		return nil
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		f, ok := v.(float64)
+		if !ok || f < 0 {
+			return errors.New("not a positive number")
+		}
+		fv.SetUint(uint64(f))
+		return nil
+	case k == reflect.Float32 || k == reflect.Float64:
+		f, ok := v.(float64)
+		if !ok {
+			return errors.New("not a number")
+		}
+		fv.SetFloat(f)
+		return nil
+	}
+
+	// slices, maps, etc
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, fv.Addr().Interface())
+}
+
+// decode creates a new struct from the value
+func (b *binding) decode(val Value) (interface{}, error) {
+	var m interface{}
+	if err := val.Scan(&m); err != nil {
+		return nil, err
+	}
+
+	n := reflect.New(b.typ)
+	var errs []string
+
+	for _, f := range b.fields {
+		key := strings.Join(f.path, ".")
+
+		v := lookup(m, f.path)
+
+		if v == nil && len(f.def) > 0 {
+			d, err := f.parse(f.def)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s bad default %v", key, err))
+				continue
+			}
+			// round trip so defaults look like config
+			db, _ := json.Marshal(d)
+			json.Unmarshal(db, &v)
+		}
+
+		if v == nil {
+			if f.required {
+				errs = append(errs, fmt.Sprintf("%s is required", key))
+			}
+			continue
+		}
+
+		if err := assign(n.Elem().FieldByIndex(f.index), v); err != nil {
+			errs = append(errs, fmt.Sprintf("%s %v", key, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config: %s", strings.Join(errs, ", "))
+	}
+
+	return n.Interface(), nil
+}
+
+func (b *binding) run() {
+	for {
+		val, err := b.w.Next()
+		if err != nil {
+			return
+		}
+
+		v, err := b.decode(val)
+		if err != nil {
+			log.Printf("Failed to bind config %v", err)
+			continue
+		}
+
+		b.value.Store(v)
+
+		b.Lock()
+		fns := b.fns
+		b.Unlock()
+
+		for _, fn := range fns {
+			fn(v)
+		}
+	}
+}
+
+func (b *binding) Load() interface{} {
+	return b.value.Load()
+}
+
+func (b *binding) OnChange(fn func(interface{})) {
+	b.Lock()
+	b.fns = append(b.fns, fn)
+	b.Unlock()
+}
+
+func (b *binding) Stop() error {
+	return b.w.Stop()
+}
+
+func newBinding(c Config, v interface{}, path ...string) (Binding, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("bind requires a pointer to a struct")
+	}
+
+	b := &binding{
+		typ:    t.Elem(),
+		fields: fields(t.Elem(), nil, nil),
+	}
+
+	// watch first so no change is missed
+	w, err := c.Watch(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := b.decode(c.Get(path...))
+	if err != nil {
+		w.Stop()
+		return nil, err
+	}
+
+	// populate the callers struct once
+	reflect.ValueOf(v).Elem().Set(reflect.ValueOf(n).Elem())
+	b.value.Store(n)
+
+	b.w = w
+	go b.run()
+
+	return b, nil
+}
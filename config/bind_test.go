@@ -0,0 +1,69 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-os/config"
+	"github.com/micro/go-os/config/source/memory"
+)
+
+type testDB struct {
+	Host    string            `config:"host,required"`
+	Port    int               `config:"port" default:"5432"`
+	Timeout time.Duration     `config:"timeout" default:"5s"`
+	Tags    []string          `config:"tags"`
+	Labels  map[string]string `config:"labels"`
+}
+
+func TestBind(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{"db": {"host": "localhost", "tags": ["a"], "labels": {"env": "dev"}}}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	var db testDB
+
+	b, err := config.Bind(c, &db, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	if db.Host != "localhost" || db.Port != 5432 || db.Timeout != time.Second*5 {
+		t.Fatalf("Unexpected struct %+v", db)
+	}
+
+	if len(db.Tags) != 1 || db.Labels["env"] != "dev" {
+		t.Fatalf("Unexpected struct %+v", db)
+	}
+
+	changed := make(chan *testDB, 1)
+	b.OnChange(func(v interface{}) {
+		changed <- v.(*testDB)
+	})
+
+	waitWatched(t, src)
+
+	src.Update([]byte(`{"db": {"host": "db.local", "port": 3306, "timeout": "1m"}}`))
+
+	select {
+	case v := <-changed:
+		if v.Host != "db.local" || v.Port != 3306 || v.Timeout != time.Minute {
+			t.Fatalf("Unexpected struct %+v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for change")
+	}
+
+	if v := b.Load().(*testDB); v.Host != "db.local" {
+		t.Fatalf("Expected db.local got %s", v.Host)
+	}
+
+	if _, err := config.Bind(c, &db, "missing"); err == nil {
+		t.Fatal("Expected error binding without required host")
+	}
+}
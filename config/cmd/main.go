@@ -0,0 +1,190 @@
+// Command config gets, sets and diffs keys against a config source
+//
+//	config -source=file -name=config.json get db.host
+//	config -source=consul -name=/micro/config set db.port 5432
+//	config -source=etcd -name=/micro/config diff config.json
+//
+// Values are set as json if they parse, otherwise as strings.
+// Writes use compare and swap so concurrent changes aren't lost.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/micro/go-os/config"
+	"github.com/micro/go-os/config/source/consul"
+	"github.com/micro/go-os/config/source/etcd"
+	"github.com/micro/go-os/config/source/file"
+)
+
+var (
+	sourceFlag = flag.String("source", "file", "Source to use; file, consul, etcd")
+	nameFlag   = flag.String("name", "", "Name of the file or key")
+	hostsFlag  = flag.String("hosts", "", "Comma separated hosts for consul or etcd")
+	formatFlag = flag.String("format", "", "Format of the data; json, yaml, toml, hcl, properties")
+
+	// attempts at a write before giving up on conflicts
+	retries = 3
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: config [flags] get [path] | set path value | diff file\n\n")
+	flag.PrintDefaults()
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func path(p string) []string {
+	if len(p) == 0 {
+		return nil
+	}
+	return strings.Split(p, ".")
+}
+
+func newSource() (config.WritableSource, error) {
+	var opts []config.SourceOption
+
+	if len(*nameFlag) > 0 {
+		opts = append(opts, config.SourceName(*nameFlag))
+	}
+
+	if len(*hostsFlag) > 0 {
+		opts = append(opts, config.SourceHosts(strings.Split(*hostsFlag, ",")...))
+	}
+
+	if len(*formatFlag) > 0 {
+		opts = append(opts, config.SourceFormat(*formatFlag))
+	}
+
+	switch *sourceFlag {
+	case "file":
+		return file.NewSource(opts...), nil
+	case "consul":
+		return consul.NewSource(opts...), nil
+	case "etcd":
+		return etcd.NewSource(opts...), nil
+	}
+
+	return nil, fmt.Errorf("unknown source %s", *sourceFlag)
+}
+
+// read returns the merged change set of the source without
+// resolving references so it's what is stored
+func read(s config.Source) (*config.ChangeSet, error) {
+	ch, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+	return config.NewReader(config.ReaderInterpolate(false)).Parse(ch)
+}
+
+func get(s config.Source, p string) error {
+	ch, err := read(s)
+	if err != nil {
+		return err
+	}
+
+	vals, err := config.NewReader().Values(ch)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(vals.Get(path(p)...).Bytes()))
+	return nil
+}
+
+func set(s config.WritableSource, p, v string) error {
+	var val interface{}
+	if err := json.Unmarshal([]byte(v), &val); err != nil {
+		val = v
+	}
+
+	var err error
+
+	for i := 0; i < retries; i++ {
+		_, err = config.WriteSource(s, func(vals config.Values) error {
+			vals.Set(val, path(p)...)
+			return nil
+		})
+		if err != config.ErrConflict {
+			break
+		}
+	}
+
+	return err
+}
+
+func diff(s config.Source, name string) error {
+	from, err := read(s)
+	if err != nil {
+		return err
+	}
+
+	to, err := read(file.NewSource(config.SourceName(name)))
+	if err != nil {
+		return err
+	}
+
+	d, err := config.Compare(from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range []struct {
+		prefix string
+		paths  [][]string
+	}{
+		{"+", d.Added},
+		{"-", d.Removed},
+		{"~", d.Modified},
+	} {
+		for _, p := range c.paths {
+			fmt.Println(c.prefix, strings.Join(p, "."))
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	s, err := newSource()
+	if err != nil {
+		fatal(err)
+	}
+
+	switch {
+	case args[0] == "get" && len(args) <= 2:
+		var p string
+		if len(args) == 2 {
+			p = args[1]
+		}
+		err = get(s, p)
+	case args[0] == "set" && len(args) == 3:
+		err = set(s, args[1], args[2])
+	case args[0] == "diff" && len(args) == 2:
+		err = diff(s, args[1])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fatal(err)
+	}
+}
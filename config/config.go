@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"time"
 )
 
@@ -14,6 +15,20 @@ type Config interface {
 	Options() Options
 	// Watch for changes
 	Watch(path ...string) (Watcher, error)
+	// Values were loaded from the snapshot
+	// as sources were unreachable
+	Stale() bool
+	// Versions of the merged config, newest first
+	Versions() []*Version
+	// Rollback to a version, which may be the current
+	// one. Changes from sources are ignored until Unpin.
+	Rollback(checksum string) error
+	// Unpin applies the latest config from sources
+	Unpin() error
+	// Write changes to the writable source. The func is
+	// given the source's values rather than the merged
+	// values. Returns ErrConflict if the source changed.
+	Write(fn func(Values) error) error
 	// Render config unusable
 	Close() error
 	// String name of config; platform
@@ -59,11 +74,36 @@ type Source interface {
 	String() string
 }
 
+// Watcher watches a path for changes. Changes are delivered
+// in order. A caller which falls far behind gets the latest
+// changes coalesced into one. Stop the watcher when done.
 type Watcher interface {
+	// Next returns the new value
 	Next() (Value, error)
+	// NextChange returns the old and new value
+	// along with the paths which changed
+	NextChange() (*Change, error)
 	Stop() error
 }
 
+// Change is a change to the value of a watched path
+type Change struct {
+	Old Value
+	New Value
+	// Paths relative to the watched path. An empty
+	// path is the watched value itself.
+	Diff *Diff
+}
+
+// WritableSource is a source config can be written back to
+type WritableSource interface {
+	Source
+	// Write replaces the data if the checksum of the source still
+	// matches prev e.g. the change set last read. Otherwise it
+	// returns ErrConflict. A nil prev writes unconditionally.
+	Write(prev *ChangeSet, data []byte) (*ChangeSet, error)
+}
+
 // SourceWatcher allows you to watch a source for changes
 // Next is a blocking call which returns the next
 // ChangeSet update. Stop Renders the watcher unusable.
@@ -83,6 +123,26 @@ type Reader interface {
 	String() string
 }
 
+// Binding keeps a struct bound to a config path up to date
+type Binding interface {
+	// Load returns the latest struct, a pointer of the bound type.
+	// It's replaced on change so is safe to use without locking.
+	Load() interface{}
+	// OnChange is called with the new struct after a change
+	OnChange(fn func(interface{}))
+	// Stop updating
+	Stop() error
+}
+
+// Schema validates merged config before it's used.
+// Config which fails is rejected and the previous
+// values are kept.
+type Schema interface {
+	// Validate may set defaults for missing values
+	Validate(Values) error
+	String() string
+}
+
 // ChangeSet represents a set an actual source
 type ChangeSet struct {
 	// The time at which the last change occured
@@ -93,17 +153,60 @@ type ChangeSet struct {
 	Checksum string
 	// The source of this change; file, consul, etcd
 	Source string
+	// The format of the data; json, yaml, toml, hcl,
+	// properties. Empty is treated as json.
+	Format string
+}
+
+// Version is merged config kept in the history
+type Version struct {
+	// The time at which the version was applied
+	Timestamp time.Time
+	// The merged change set, its checksum identifies the version
+	ChangeSet *ChangeSet
 }
 
 type Option func(o *Options)
 
 type SourceOption func(o *SourceOptions)
 
+type ReaderOption func(o *ReaderOptions)
+
 var (
 	DefaultPollInterval = time.Second * 30
 	DefaultSourceName   = "MICRO:CONFIG"
+	DefaultFormat       = "json"
+	DefaultHistory      = 10
+
+	// ErrConflict is returned when a source changed during a write
+	ErrConflict = errors.New("config source changed")
+	// ErrNotFound is returned by writable sources which
+	// have nothing to read because it's not written yet
+	ErrNotFound = errors.New("config source not found")
 )
 
 func NewConfig(opts ...Option) Config {
 	return newPlatform(opts...)
 }
+
+// Bind a pointer to a struct to the config path. The struct is
+// populated from config then Load returns a new copy each time
+// the path changes. Fields use the same tags as NewSchema.
+func Bind(c Config, v interface{}, path ...string) (Binding, error) {
+	return newBinding(c, v, path...)
+}
+
+// NewSchema creates a schema from the config tags of a struct e.g.
+//
+//	type DB struct {
+//		Host    string        `config:"host,required"`
+//		Port    int           `config:"port" default:"5432" min:"1" max:"65535"`
+//		Timeout time.Duration `config:"timeout" default:"5s"`
+//	}
+//
+//	config.NewSchema(DB{})
+//
+// Min and max are the length of strings, lists and maps.
+func NewSchema(v interface{}) Schema {
+	return newSchema(v)
+}
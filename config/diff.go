@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diff is the paths which changed between two change sets
+type Diff struct {
+	Added    [][]string
+	Removed  [][]string
+	Modified [][]string
+}
+
+// leaves flattens the value to its leaf paths. Lists are leaves
+// and nulls are treated as missing. A value which isn't a map is
+// a leaf with an empty path.
+func leaves(v interface{}, path []string, out map[string]interface{}) {
+	if v == nil {
+		return
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		out[strings.Join(path, "\x00")] = v
+		return
+	}
+	for k, val := range m {
+		leaves(val, append(append([]string{}, path...), k), out)
+	}
+}
+
+func split(key string) []string {
+	if len(key) == 0 {
+		return []string{}
+	}
+	return strings.Split(key, "\x00")
+}
+
+func sorted(keys []string) [][]string {
+	sort.Strings(keys)
+	var paths [][]string
+	for _, k := range keys {
+		paths = append(paths, split(k))
+	}
+	return paths
+}
+
+// diff compares the decoded values
+func diff(o, n interface{}) *Diff {
+	ol := make(map[string]interface{})
+	nl := make(map[string]interface{})
+	leaves(o, nil, ol)
+	leaves(n, nil, nl)
+
+	var added, removed, modified []string
+
+	for k, v := range nl {
+		ov, ok := ol[k]
+		if !ok {
+			added = append(added, k)
+		} else if !reflect.DeepEqual(ov, v) {
+			modified = append(modified, k)
+		}
+	}
+
+	for k := range ol {
+		if _, ok := nl[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+
+	return &Diff{
+		Added:    sorted(added),
+		Removed:  sorted(removed),
+		Modified: sorted(modified),
+	}
+}
+
+// diffValues compares the values of a watched path
+func diffValues(o, n Value) *Diff {
+	var ov, nv interface{}
+	o.Scan(&ov)
+	n.Scan(&nv)
+	return diff(ov, nv)
+}
+
+func decode(ch *ChangeSet) (interface{}, error) {
+	var v interface{}
+	if ch == nil || len(ch.Data) == 0 {
+		return v, nil
+	}
+	if err := json.Unmarshal(ch.Data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Compare returns the paths which changed between the merged
+// change sets e.g. two versions from the config history
+func Compare(o, n *ChangeSet) (*Diff, error) {
+	ov, err := decode(o)
+	if err != nil {
+		return nil, err
+	}
+	nv, err := decode(n)
+	if err != nil {
+		return nil, err
+	}
+	return diff(ov, nv), nil
+}
+
+// Empty returns true if nothing changed
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
@@ -0,0 +1,11 @@
+// Package encoder converts config between data formats
+// e.g yaml, toml, so sources of different formats can
+// be merged by the reader.
+package encoder
+
+type Encoder interface {
+	Encode(interface{}) ([]byte, error)
+	Decode([]byte, interface{}) error
+	// Name of the format; json, yaml
+	String() string
+}
@@ -0,0 +1,62 @@
+package hcl
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/hcl"
+	"github.com/micro/go-os/config/encoder"
+)
+
+type hclEncoder struct{}
+
+// flatten merges the lists of objects hcl decodes
+// blocks to so `db { host = "x" }` is a plain map
+func flatten(v interface{}) interface{} {
+	switch t := v.(type) {
+	case []map[string]interface{}:
+		m := make(map[string]interface{})
+		for _, o := range t {
+			for k, val := range o {
+				m[k] = flatten(val)
+			}
+		}
+		return m
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = flatten(val)
+		}
+		return t
+	case []interface{}:
+		for i, val := range t {
+			t[i] = flatten(val)
+		}
+		return t
+	}
+	return v
+}
+
+// Encode writes json which is valid hcl
+func (h hclEncoder) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (h hclEncoder) Decode(d []byte, v interface{}) error {
+	var m map[string]interface{}
+	if err := hcl.Unmarshal(d, &m); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(flatten(m))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (h hclEncoder) String() string {
+	return "hcl"
+}
+
+func NewEncoder() encoder.Encoder {
+	return hclEncoder{}
+}
@@ -0,0 +1,113 @@
+package properties
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/magiconair/properties"
+	"github.com/micro/go-os/config/encoder"
+)
+
+type propertiesEncoder struct{}
+
+// flatten nested maps into dotted keys
+func flatten(prefix string, v interface{}, out map[string]string) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		out[prefix] = fmt.Sprintf("%v", v)
+		return
+	}
+	for k, val := range m {
+		if len(prefix) > 0 {
+			k = prefix + "." + k
+		}
+		flatten(k, val, out)
+	}
+}
+
+func (p propertiesEncoder) Encode(v interface{}) ([]byte, error) {
+	// get a plain map to flatten
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]string)
+	flatten("", m, flat)
+
+	var keys []string
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	props := properties.NewProperties()
+	for _, k := range keys {
+		if _, _, err := props.Set(k, flat[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := props.Write(buf, properties.UTF8); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode nests dotted keys e.g db.host=x is {"db": {"host": "x"}}.
+// Values are always strings.
+func (p propertiesEncoder) Decode(d []byte, v interface{}) error {
+	props, err := properties.Load(d, properties.UTF8)
+	if err != nil {
+		return err
+	}
+
+	keys := props.Keys()
+	sort.Strings(keys)
+
+	m := make(map[string]interface{})
+
+	for _, k := range keys {
+		val, _ := props.Get(k)
+		parts := strings.Split(k, ".")
+
+		cur := m
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := cur[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				cur[part] = next
+			}
+			cur = next
+		}
+
+		// nested keys take precedence over a value
+		last := parts[len(parts)-1]
+		if _, ok := cur[last].(map[string]interface{}); !ok {
+			cur[last] = val
+		}
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (p propertiesEncoder) String() string {
+	return "properties"
+}
+
+func NewEncoder() encoder.Encoder {
+	return propertiesEncoder{}
+}
@@ -0,0 +1,56 @@
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/micro/go-os/config/encoder"
+	"gopkg.in/yaml.v2"
+)
+
+type yamlEncoder struct{}
+
+// normalize converts the map[interface{}]interface{} maps
+// yaml decodes to map[string]interface{} so they're json
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprintf("%v", k)] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = normalize(val)
+		}
+		return t
+	}
+	return v
+}
+
+func (y yamlEncoder) Encode(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (y yamlEncoder) Decode(d []byte, v interface{}) error {
+	var i interface{}
+	if err := yaml.Unmarshal(d, &i); err != nil {
+		return err
+	}
+
+	// round trip through json for the types to line up
+	b, err := json.Marshal(normalize(i))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (y yamlEncoder) String() string {
+	return "yaml"
+}
+
+func NewEncoder() encoder.Encoder {
+	return yamlEncoder{}
+}
@@ -0,0 +1,198 @@
+package flags
+
+import (
+	"hash/fnv"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-os/config"
+	"github.com/micro/go-os/metrics"
+
+	"golang.org/x/net/context"
+)
+
+type flags struct {
+	opts Options
+	w    config.Watcher
+
+	// map[string]*Flag
+	value atomic.Value
+}
+
+func newFlags(c config.Config, opts ...Option) Flags {
+	options := Options{
+		Path: DefaultPath,
+		Key:  DefaultKey,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	f := &flags{
+		opts: options,
+	}
+
+	// watch first so no change is missed
+	w, err := c.Watch(options.Path...)
+	if err != nil {
+		log.Printf("Failed to watch flags %v", err)
+	}
+
+	f.load(c.Get(options.Path...))
+
+	if w != nil {
+		f.w = w
+		go f.run()
+	}
+
+	return f
+}
+
+// load replaces the flags, keeping the previous ones if invalid
+func (f *flags) load(v config.Value) {
+	var fl map[string]*Flag
+	if err := v.Scan(&fl); err != nil {
+		log.Printf("Failed to load flags %v", err)
+		if f.value.Load() == nil {
+			f.value.Store(map[string]*Flag{})
+		}
+		return
+	}
+
+	if fl == nil {
+		fl = make(map[string]*Flag)
+	}
+
+	f.value.Store(fl)
+}
+
+func (f *flags) run() {
+	for {
+		v, err := f.w.Next()
+		if err != nil {
+			return
+		}
+		f.load(v)
+	}
+}
+
+// bucket returns a stable percentage for the flag and key
+func bucket(name, key string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + key))
+	return float64(h.Sum32()%10000) / 100
+}
+
+// get returns request metadata ignoring the case of the key
+func get(md metadata.Metadata, k string) (string, bool) {
+	if v, ok := md[k]; ok {
+		return v, true
+	}
+	for mk, v := range md {
+		if strings.EqualFold(mk, k) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// match returns true if the value matches the pattern e.g. 1.2.*
+func match(pattern, v string) bool {
+	if ok, err := path.Match(pattern, v); err == nil && ok {
+		return true
+	}
+	return pattern == v
+}
+
+func (f *flags) matches(r *Rule, name string, md metadata.Metadata) bool {
+	s := f.opts.Service
+
+	if len(r.Service) > 0 && (s == nil || !match(r.Service, s.Name)) {
+		return false
+	}
+
+	if len(r.Version) > 0 && (s == nil || !match(r.Version, s.Version)) {
+		return false
+	}
+
+	if len(r.Metadata) > 0 {
+		if s == nil || len(s.Nodes) == 0 {
+			return false
+		}
+		for k, v := range r.Metadata {
+			if s.Nodes[0].Metadata[k] != v {
+				return false
+			}
+		}
+	}
+
+	for k, v := range r.Request {
+		if mv, ok := get(md, k); !ok || mv != v {
+			return false
+		}
+	}
+
+	if r.Percentage != nil {
+		k := r.Key
+		if len(k) == 0 {
+			k = f.opts.Key
+		}
+		// without a key there's nothing to bucket by
+		v, ok := get(md, k)
+		if !ok || bucket(name, v) >= *r.Percentage {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *flags) evaluate(ctx context.Context, name string) bool {
+	fl, ok := f.Flags()[name]
+	if !ok || fl == nil {
+		return false
+	}
+
+	md, _ := metadata.FromContext(ctx)
+
+	for _, r := range fl.Rules {
+		if r != nil && f.matches(r, name, md) {
+			return r.Value
+		}
+	}
+
+	return fl.Default
+}
+
+func (f *flags) Enabled(ctx context.Context, name string) bool {
+	v := f.evaluate(ctx, name)
+
+	if f.opts.Metrics != nil {
+		f.opts.Metrics.Counter("flags.evaluations").WithFields(metrics.Fields{
+			"flag":   name,
+			"result": strconv.FormatBool(v),
+		}).Incr(1)
+	}
+
+	return v
+}
+
+func (f *flags) Flags() map[string]*Flag {
+	return f.value.Load().(map[string]*Flag)
+}
+
+func (f *flags) Close() error {
+	if f.w == nil {
+		return nil
+	}
+	return f.w.Stop()
+}
+
+func (f *flags) String() string {
+	return "config"
+}
@@ -0,0 +1,72 @@
+// Package flags evaluates feature flags defined in config
+package flags
+
+import (
+	"github.com/micro/go-os/config"
+
+	"golang.org/x/net/context"
+)
+
+// Flags evaluates feature flags defined in config. They're
+// updated as the config changes.
+type Flags interface {
+	// Enabled returns whether the flag is on for the request.
+	// Unknown flags are off.
+	Enabled(ctx context.Context, name string) bool
+	// The current flag definitions, not to be modified
+	Flags() map[string]*Flag
+	// Stop watching for changes
+	Close() error
+	String() string
+}
+
+// Flag is a feature flag. The first rule which
+// matches decides the value otherwise it's Default.
+type Flag struct {
+	Default bool    `json:"default"`
+	Rules   []*Rule `json:"rules"`
+}
+
+// Rule targets a flag. Every field set must match.
+type Rule struct {
+	// Service name, may be a pattern e.g. go.micro.srv.*
+	Service string `json:"service"`
+	// Service version, may be a pattern e.g. 1.2.*
+	Version string `json:"version"`
+	// Node metadata
+	Metadata map[string]string `json:"metadata"`
+	// Request metadata from the context
+	Request map[string]string `json:"request"`
+	// Percentage of requests, bucketed by the request
+	// metadata Key so the same key always gets the
+	// same result
+	Percentage *float64 `json:"percentage"`
+	// Request metadata to bucket by. Defaults to DefaultKey.
+	Key string `json:"key"`
+	// The flag value when the rule matches
+	Value bool `json:"value"`
+}
+
+type Option func(o *Options)
+
+var (
+	DefaultPath = []string{"flags"}
+	DefaultKey  = "X-User-Id"
+)
+
+// NewFlags evaluates the flags at the config path
+// e.g. DefaultPath
+//
+//	flags:
+//	  new-checkout:
+//	    default: false
+//	    rules:
+//	    - service: go.micro.srv.checkout
+//	      request:
+//	        X-User-Tier: gold
+//	      value: true
+//	    - percentage: 20
+//	      value: true
+func NewFlags(c config.Config, opts ...Option) Flags {
+	return newFlags(c, opts...)
+}
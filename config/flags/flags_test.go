@@ -0,0 +1,199 @@
+package flags
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-os/config"
+	"github.com/micro/go-os/config/source/memory"
+	"github.com/micro/go-os/metrics"
+
+	"golang.org/x/net/context"
+)
+
+type testMetrics struct {
+	sync.Mutex
+	counts map[string]uint64
+}
+
+type testCounter struct {
+	m      *testMetrics
+	fields metrics.Fields
+}
+
+func (m *testMetrics) Close() error                          { return nil }
+func (m *testMetrics) Init(...metrics.Option) error          { return nil }
+func (m *testMetrics) Gauge(id string) metrics.Gauge         { return nil }
+func (m *testMetrics) Histogram(id string) metrics.Histogram { return nil }
+func (m *testMetrics) String() string                        { return "test" }
+
+func (m *testMetrics) Counter(id string) metrics.Counter {
+	return &testCounter{m: m}
+}
+
+func (c *testCounter) Incr(d uint64) {
+	c.m.Lock()
+	c.m.counts[c.fields["flag"]+":"+c.fields["result"]] += d
+	c.m.Unlock()
+}
+
+func (c *testCounter) Decr(d uint64) {}
+func (c *testCounter) Reset()        {}
+
+func (c *testCounter) WithFields(f metrics.Fields) metrics.Counter {
+	return &testCounter{m: c.m, fields: f}
+}
+
+func TestFlags(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{
+		"flags": {
+			"checkout": {
+				"rules": [
+					{"request": {"X-User-Tier": "gold"}, "value": true},
+					{"service": "go.micro.srv.*", "version": "1.2.*", "metadata": {"region": "eu"}, "value": true}
+				]
+			},
+			"other-service": {
+				"default": true,
+				"rules": [{"service": "go.micro.srv.other", "value": false}]
+			}
+		}
+	}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	m := &testMetrics{counts: make(map[string]uint64)}
+
+	service := &registry.Service{
+		Name:    "go.micro.srv.checkout",
+		Version: "1.2.3",
+		Nodes: []*registry.Node{
+			{Id: "1", Metadata: map[string]string{"region": "us"}},
+		},
+	}
+
+	f := NewFlags(c, Service(service), Metrics(m))
+	defer f.Close()
+
+	gold := metadata.NewContext(context.TODO(), metadata.Metadata{"x-user-tier": "gold"})
+
+	testData := []struct {
+		ctx      context.Context
+		flag     string
+		expected bool
+	}{
+		{gold, "checkout", true},
+		// node metadata doesn't match
+		{context.TODO(), "checkout", false},
+		{context.TODO(), "other-service", true},
+		{context.TODO(), "unknown", false},
+	}
+
+	for _, d := range testData {
+		if v := f.Enabled(d.ctx, d.flag); v != d.expected {
+			t.Fatalf("Expected %s to be %v got %v", d.flag, d.expected, v)
+		}
+	}
+
+	service.Nodes[0].Metadata["region"] = "eu"
+
+	if !f.Enabled(context.TODO(), "checkout") {
+		t.Fatal("Expected checkout to be enabled in eu")
+	}
+
+	m.Lock()
+	if n := m.counts["checkout:true"]; n != 2 {
+		t.Fatalf("Expected 2 evaluations of checkout true got %d", n)
+	}
+	if n := m.counts["checkout:false"]; n != 1 {
+		t.Fatalf("Expected 1 evaluation of checkout false got %d", n)
+	}
+	m.Unlock()
+}
+
+func TestPercentage(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{
+		"flags": {"rollout": {"rules": [{"percentage": 20, "value": true}]}}
+	}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	f := NewFlags(c)
+	defer f.Close()
+
+	if f.Enabled(context.TODO(), "rollout") {
+		t.Fatal("Expected rollout to be off without a key")
+	}
+
+	var on int
+
+	for i := 0; i < 1000; i++ {
+		ctx := metadata.NewContext(context.TODO(), metadata.Metadata{DefaultKey: fmt.Sprintf("user-%d", i)})
+
+		v := f.Enabled(ctx, "rollout")
+		if v {
+			on++
+		}
+
+		// stable for the same key
+		if f.Enabled(ctx, "rollout") != v {
+			t.Fatal("Expected the same result for the same key")
+		}
+	}
+
+	if on < 150 || on > 250 {
+		t.Fatalf("Expected around 200 of 1000 enabled got %d", on)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{"flags": {"foo": {"default": false}}}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	f := NewFlags(c)
+	defer f.Close()
+
+	if f.Enabled(context.TODO(), "foo") {
+		t.Fatal("Expected foo to be off")
+	}
+
+	// wait for config to watch the source
+	for i := 0; ; i++ {
+		src.RLock()
+		n := len(src.Watchers)
+		src.RUnlock()
+		if n > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("Source is not being watched")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	src.Update([]byte(`{"flags": {"foo": {"default": true}}}`))
+
+	for i := 0; !f.Enabled(context.TODO(), "foo"); i++ {
+		if i > 100 {
+			t.Fatal("Expected foo to be turned on")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
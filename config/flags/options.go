@@ -0,0 +1,47 @@
+package flags
+
+import (
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-os/metrics"
+)
+
+type Options struct {
+	// Config path of the flags
+	Path []string
+	// Default request metadata to bucket by
+	Key string
+	// Service the flags are evaluated for
+	Service *registry.Service
+	// Records evaluations
+	Metrics metrics.Metrics
+}
+
+// Path is the config path of the flags
+func Path(path ...string) Option {
+	return func(o *Options) {
+		o.Path = path
+	}
+}
+
+// Key is the request metadata percentages are bucketed
+// by when a rule doesn't set its own e.g. a user id
+func Key(k string) Option {
+	return func(o *Options) {
+		o.Key = k
+	}
+}
+
+// Service is matched by the service, version and metadata
+// of rules. Node metadata is taken from the first node.
+func Service(s *registry.Service) Option {
+	return func(o *Options) {
+		o.Service = s
+	}
+}
+
+// Metrics counts evaluations by flag and result
+func Metrics(m metrics.Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+// readHistory loads the versions saved to the file
+func readHistory(file string) ([]*Version, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*Version
+	if err := json.Unmarshal(b, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// record adds the change set to the history if it's new.
+// The oldest versions are dropped once it's full.
+func (p *platform) record(set *ChangeSet) {
+	if p.opts.History <= 0 {
+		return
+	}
+
+	p.Lock()
+
+	if n := len(p.versions); n > 0 && p.versions[n-1].ChangeSet.Checksum == set.Checksum {
+		p.Unlock()
+		return
+	}
+
+	p.versions = append(p.versions, &Version{
+		Timestamp: time.Now(),
+		ChangeSet: set,
+	})
+
+	if n := len(p.versions) - p.opts.History; n > 0 {
+		p.versions = p.versions[n:]
+	}
+
+	versions := p.versions
+	p.Unlock()
+
+	if len(p.opts.HistoryFile) == 0 {
+		return
+	}
+
+	if err := writeFile(p.opts.HistoryFile, versions); err != nil {
+		log.Printf("Failed to write history %v", err)
+	}
+}
+
+func (p *platform) Versions() []*Version {
+	p.RLock()
+	defer p.RUnlock()
+
+	versions := make([]*Version, 0, len(p.versions))
+	for i := len(p.versions) - 1; i >= 0; i-- {
+		versions = append(versions, p.versions[i])
+	}
+	return versions
+}
+
+func (p *platform) Rollback(checksum string) error {
+	// no merging while we roll back
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var version *Version
+
+	p.RLock()
+	for _, v := range p.versions {
+		if v.ChangeSet.Checksum == checksum {
+			version = v
+		}
+	}
+	p.RUnlock()
+
+	if version == nil {
+		return errors.New("unknown version " + checksum)
+	}
+
+	vals, set, err := p.values(version.ChangeSet)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	p.vals = vals
+	p.cset = set
+	p.stale = false
+	p.pinned = true
+	p.Unlock()
+
+	p.update()
+
+	return nil
+}
+
+func (p *platform) Unpin() error {
+	p.Lock()
+	p.pinned = false
+	p.Unlock()
+
+	p.merge()
+	return nil
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// interpolator resolves references in merged config e.g.
+//
+//	${db.host}             the value at db.host
+//	${env:DB_HOST}         the environment variable
+//	${env:DB_HOST:-local}  with a default
+//
+// A string which is only a reference takes the type of the
+// value it references. $${ escapes a literal ${.
+type interpolator struct {
+	root map[string]interface{}
+
+	// resolved paths
+	done map[string]bool
+	// paths being resolved, to detect cycles
+	stack []string
+
+	errs []string
+}
+
+var errMissing = errors.New("missing")
+
+// interpolate resolves references in place
+func interpolate(m map[string]interface{}) error {
+	i := &interpolator{
+		root: m,
+		done: make(map[string]bool),
+	}
+
+	for k := range m {
+		i.resolvePath([]string{k})
+	}
+
+	if len(i.errs) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(i.errs, ", "))
+	}
+
+	return nil
+}
+
+// get returns the value at the path and its parent
+func (i *interpolator) get(path []string) (map[string]interface{}, interface{}, bool) {
+	parent := i.root
+	for n, p := range path {
+		v, ok := parent[p]
+		if !ok {
+			return nil, nil, false
+		}
+		if n == len(path)-1 {
+			return parent, v, true
+		}
+		if parent, ok = v.(map[string]interface{}); !ok {
+			return nil, nil, false
+		}
+	}
+	return nil, nil, false
+}
+
+// resolvePath resolves the value at the path and saves it
+func (i *interpolator) resolvePath(path []string) (interface{}, error) {
+	key := strings.Join(path, ".")
+
+	parent, v, ok := i.get(path)
+	if !ok {
+		return nil, errMissing
+	}
+
+	if i.done[key] {
+		return v, nil
+	}
+
+	for n, p := range i.stack {
+		if p == key {
+			cycle := append(append([]string{}, i.stack[n:]...), key)
+			return nil, fmt.Errorf("reference cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	i.stack = append(i.stack, key)
+	v = i.resolve(v, path, true)
+	i.stack = i.stack[:len(i.stack)-1]
+
+	parent[path[len(path)-1]] = v
+	i.done[key] = true
+
+	return v, nil
+}
+
+// resolve the value. Maps which aren't in lists can be
+// referenced so are resolved by path.
+func (i *interpolator) resolve(v interface{}, path []string, addressable bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k := range t {
+			p := append(append([]string{}, path...), k)
+			if addressable {
+				i.resolvePath(p)
+				continue
+			}
+			t[k] = i.resolve(t[k], p, false)
+		}
+		return t
+	case []interface{}:
+		for n := range t {
+			t[n] = i.resolve(t[n], append(append([]string{}, path...), strconv.Itoa(n)), false)
+		}
+		return t
+	case string:
+		return i.expand(t, path)
+	}
+	return v
+}
+
+// lookup returns the value of a reference
+func (i *interpolator) lookup(ref string) (interface{}, error) {
+	ref, def := ref, ""
+	hasDef := false
+
+	if n := strings.Index(ref, ":-"); n >= 0 {
+		ref, def, hasDef = ref[:n], ref[n+2:], true
+	}
+
+	var v interface{}
+	err := errMissing
+
+	if strings.HasPrefix(ref, "env:") {
+		if s, ok := os.LookupEnv(strings.TrimPrefix(ref, "env:")); ok {
+			v, err = s, nil
+		}
+	} else if len(ref) > 0 {
+		v, err = i.resolvePath(strings.Split(ref, "."))
+	}
+
+	if err == errMissing && hasDef {
+		return def, nil
+	}
+
+	return v, err
+}
+
+// expand replaces the references in the string
+func (i *interpolator) expand(s string, path []string) interface{} {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	key := strings.Join(path, ".")
+	orig := s
+
+	var out []string
+
+	for len(s) > 0 {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			out = append(out, s)
+			break
+		}
+
+		// escaped
+		if start > 0 && s[start-1] == '$' {
+			out = append(out, s[:start-1]+"${")
+			s = s[start+2:]
+			continue
+		}
+
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			out = append(out, s)
+			break
+		}
+		end += start
+
+		ref := s[start+2 : end]
+
+		v, err := i.lookup(ref)
+		if err == errMissing {
+			err = errors.New("unresolved reference ${" + ref + "}")
+		}
+		if err != nil {
+			i.errs = append(i.errs, fmt.Sprintf("%s %v", key, err))
+			return orig
+		}
+
+		// the whole value keeps its type
+		if start == 0 && end == len(s)-1 && len(out) == 0 {
+			return v
+		}
+
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			i.errs = append(i.errs, fmt.Sprintf("%s can't embed ${%s} in a string", key, ref))
+			return orig
+		case nil:
+			v = ""
+		}
+
+		out = append(out, s[:start], fmt.Sprintf("%v", v))
+		s = s[end+1:]
+	}
+
+	return strings.Join(out, "")
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/micro/go-micro/client"
+	"github.com/micro/go-os/config/encoder"
 
 	"golang.org/x/net/context"
 )
@@ -13,6 +14,22 @@ type Options struct {
 	Reader       Reader
 	Sources      []Source
 	Client       client.Client
+	// File the last good config is saved to
+	Snapshot string
+	// Number of versions kept in the history
+	History int
+	// File the history is saved to
+	HistoryFile string
+	// Validates config before it's used
+	Schema Schema
+	// Receives config which was rejected
+	Errors chan<- error
+	// Profile selected from each source e.g. staging
+	Environment string
+	// Resolve references in the merged config
+	Interpolate bool
+	// Source changes are written to
+	Writer WritableSource
 }
 
 type SourceOptions struct {
@@ -23,12 +40,19 @@ type SourceOptions struct {
 	Client client.Client
 	Hosts  []string
 
+	// Format of the data; json, yaml, etc
+	Format string
+
+	// Namespace of the config e.g. the service name
+	Namespace string
+
 	// Extra Options
 	Context context.Context
 }
 
 // PollInterval is the time interval at which the sources are polled
-// to retrieve config.
+// to retrieve config. Sources are watched for changes so polling
+// only happens while a source watcher is down.
 func PollInterval(i time.Duration) Option {
 	return func(o *Options) {
 		o.PollInterval = i
@@ -44,6 +68,76 @@ func WithSource(s Source) Option {
 	}
 }
 
+// Snapshot saves the last good merged config to the file. It's
+// used in place of sources which are unreachable e.g at startup,
+// under those which have loaded, and the config is marked stale
+// until every source has been read.
+func Snapshot(file string) Option {
+	return func(o *Options) {
+		o.Snapshot = file
+	}
+}
+
+// History is the number of previous versions of the merged
+// config to keep so they can be compared or rolled back to.
+// Zero or less disables the history.
+func History(n int) Option {
+	return func(o *Options) {
+		o.History = n
+	}
+}
+
+// HistoryFile saves the history to the file so it's
+// available after a restart.
+func HistoryFile(file string) Option {
+	return func(o *Options) {
+		o.HistoryFile = file
+	}
+}
+
+// WithSchema validates merged config. Config which fails
+// is rejected, keeping the previous values.
+func WithSchema(s Schema) Option {
+	return func(o *Options) {
+		o.Schema = s
+	}
+}
+
+// Errors receives errors for config which was rejected e.g
+// by the schema. Errors are dropped if the channel is full.
+func Errors(ch chan<- error) Option {
+	return func(o *Options) {
+		o.Errors = ch
+	}
+}
+
+// Environment selects the base config and the overlay for the
+// environment from each source e.g. environments.staging. It's
+// set on the default reader, custom readers should be created
+// with ReaderEnvironment.
+func Environment(name string) Option {
+	return func(o *Options) {
+		o.Environment = name
+	}
+}
+
+// Interpolate resolves ${path.to.key} and ${env:NAME} references
+// in the merged config. It's set on the default reader, custom
+// readers should be created with ReaderInterpolate.
+func Interpolate(b bool) Option {
+	return func(o *Options) {
+		o.Interpolate = b
+	}
+}
+
+// WithWriter is the source Write saves changes to. It should
+// also be one of the sources so changes are loaded.
+func WithWriter(s WritableSource) Option {
+	return func(o *Options) {
+		o.Writer = s
+	}
+}
+
 func WithClient(c client.Client) Option {
 	return func(o *Options) {
 		o.Client = c
@@ -59,6 +153,15 @@ func WithReader(r Reader) Option {
 	}
 }
 
+type ReaderOptions struct {
+	// Encoders by format
+	Encoding map[string]encoder.Encoder
+	// Resolve ${path.to.key} and ${env:NAME} references
+	Interpolate bool
+	// Profile selected from each change set
+	Environment string
+}
+
 // Source options
 
 // SourceName is an option to provide name of a file,
@@ -80,3 +183,46 @@ func SourceHosts(hosts ...string) SourceOption {
 		o.Hosts = hosts
 	}
 }
+
+// SourceFormat is the format of the data in the source e.g yaml.
+// The reader must have an encoder for the format. Defaults to json.
+func SourceFormat(f string) SourceOption {
+	return func(o *SourceOptions) {
+		o.Format = f
+	}
+}
+
+// SourceNamespace only loads config in the namespace e.g. the
+// service name. The platform source asks the config service
+// for the namespace path.
+func SourceNamespace(ns string) SourceOption {
+	return func(o *SourceOptions) {
+		o.Namespace = ns
+	}
+}
+
+// Reader options
+
+// ReaderEncoding adds an encoder for the format it names
+// so ChangeSets in that format can be parsed.
+func ReaderEncoding(e encoder.Encoder) ReaderOption {
+	return func(o *ReaderOptions) {
+		o.Encoding[e.String()] = e
+	}
+}
+
+// ReaderInterpolate sets whether ${path.to.key} and ${env:NAME}
+// references are resolved after merging. It's off by default.
+func ReaderInterpolate(b bool) ReaderOption {
+	return func(o *ReaderOptions) {
+		o.Interpolate = b
+	}
+}
+
+// ReaderEnvironment selects the base config and the overlay
+// for the environment from each change set before merging.
+func ReaderEnvironment(name string) ReaderOption {
+	return func(o *ReaderOptions) {
+		o.Environment = name
+	}
+}
@@ -2,43 +2,81 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/micro/go-micro/client"
+	hash "github.com/mitchellh/hashstructure"
 )
 
 type platform struct {
 	exit chan bool
 	opts Options
+	once sync.Once
+
+	// serialises merges
+	mtx sync.Mutex
 
 	sync.RWMutex
 	cset *ChangeSet
 	vals Values
+	// loaded from the snapshot
+	stale bool
+	// rolled back, ignoring sources
+	pinned bool
+	// previous versions, oldest first
+	versions []*Version
+
+	// latest change set of each source
+	sets []*ChangeSet
+	// sources with a running watcher
+	watching []bool
 
 	idx      int
 	watchers map[int]*watcher
 }
 
+var (
+	maxBackoff = time.Minute
+	// values queued for a watcher before they're coalesced
+	maxQueue = 64
+)
+
 type watcher struct {
-	exit    chan bool
-	path    []string
-	value   Value
-	updates chan Value
+	exit   chan bool
+	once   sync.Once
+	path   []string
+	value  Value
+	notify chan bool
+
+	// values not yet returned by Next
+	sync.Mutex
+	queue []Value
 }
 
 func newPlatform(opts ...Option) Config {
 	options := Options{
 		PollInterval: DefaultPollInterval,
-		Reader:       NewReader(),
+		History:      DefaultHistory,
 	}
 
 	for _, o := range opts {
 		o(&options)
 	}
 
+	if options.Reader == nil {
+		options.Reader = NewReader(
+			ReaderEnvironment(options.Environment),
+			ReaderInterpolate(options.Interpolate),
+		)
+	}
+
 	if options.Client == nil {
 		options.Client = client.DefaultClient
 	}
@@ -51,20 +89,53 @@ func newPlatform(opts ...Option) Config {
 	p := &platform{
 		exit:     make(chan bool),
 		opts:     options,
+		sets:     make([]*ChangeSet, len(options.Sources)),
+		watching: make([]bool, len(options.Sources)),
 		watchers: make(map[int]*watcher),
 	}
 
+	if len(options.HistoryFile) > 0 {
+		if versions, err := readHistory(options.HistoryFile); err == nil {
+			p.versions = versions
+		}
+	}
+
 	go p.run()
 	return p
 }
 
+// backoff with jitter in the range [d/2, 3d/2)
+func backoff(attempts int) time.Duration {
+	d := time.Second * time.Duration(math.Pow(2, float64(attempts)))
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// run watches every source and polls while any aren't watched
 func (p *platform) run() {
+	// watch first so no change is missed
+	for i, s := range p.opts.Sources {
+		w, err := s.Watch()
+		if err != nil {
+			w = nil
+		}
+		go p.watch(i, s, w)
+	}
+
+	// then load everything so a single
+	// change isn't all we have
+	p.sync()
+
 	t := time.NewTicker(p.opts.PollInterval)
 
 	for {
 		select {
 		case <-t.C:
-			p.sync()
+			if p.polling() {
+				p.sync()
+			}
 		case <-p.exit:
 			t.Stop()
 			return
@@ -72,6 +143,245 @@ func (p *platform) run() {
 	}
 }
 
+// polling returns true if a source isn't being watched
+func (p *platform) polling() bool {
+	p.RLock()
+	defer p.RUnlock()
+	for _, ok := range p.watching {
+		if !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *platform) setWatching(idx int, ok bool) {
+	p.Lock()
+	p.watching[idx] = ok
+	p.Unlock()
+}
+
+// watch applies changes from the source as they happen starting
+// with the watcher given, if any. It's restarted with backoff
+// if it fails.
+func (p *platform) watch(idx int, s Source, w SourceWatcher) {
+	var attempts int
+
+	// the first attempt failed
+	if w == nil {
+		attempts++
+	}
+
+	for {
+		if w == nil {
+			select {
+			case <-time.After(backoff(attempts)):
+			case <-p.exit:
+				return
+			}
+
+			var err error
+			w, err = s.Watch()
+			if err != nil {
+				attempts++
+				continue
+			}
+		}
+
+		p.setWatching(idx, true)
+
+		var once sync.Once
+		stop := func() {
+			once.Do(func() { w.Stop() })
+		}
+
+		// stop the watcher on close
+		done := make(chan bool)
+		go func() {
+			select {
+			case <-p.exit:
+				stop()
+			case <-done:
+			}
+		}()
+
+		for {
+			ch, err := w.Next()
+			if err != nil {
+				break
+			}
+			attempts = 0
+			p.apply(idx, ch)
+		}
+
+		close(done)
+		stop()
+		w = nil
+		p.setWatching(idx, false)
+
+		select {
+		case <-p.exit:
+			return
+		default:
+		}
+
+		attempts++
+	}
+}
+
+// apply saves the change set for the source and merges
+// if it's actually changed
+func (p *platform) apply(idx int, ch *ChangeSet) {
+	p.Lock()
+	if prev := p.sets[idx]; prev != nil && prev.Checksum == ch.Checksum {
+		p.Unlock()
+		return
+	}
+	p.sets[idx] = ch
+	p.Unlock()
+
+	p.merge()
+}
+
+// merge parses the latest change set of each source
+// and updates the values and watchers
+func (p *platform) merge() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var sets []*ChangeSet
+	var missing bool
+
+	p.RLock()
+	for _, ch := range p.sets {
+		if ch == nil {
+			missing = true
+			continue
+		}
+		sets = append(sets, ch)
+	}
+	p.RUnlock()
+
+	// rather than partial config the last good snapshot stands
+	// in for sources which haven't loaded with the loaded ones
+	// on top so their changes still apply
+	var stale bool
+
+	if missing && len(p.opts.Snapshot) > 0 {
+		if snap, err := readSnapshot(p.opts.Snapshot); err == nil {
+			sets = append([]*ChangeSet{snap}, sets...)
+			stale = true
+		}
+	}
+
+	set, err := p.opts.Reader.Parse(sets...)
+	if err != nil {
+		p.error(err)
+		return
+	}
+
+	p.RLock()
+	prev := p.cset
+	p.RUnlock()
+
+	if set = p.set(set, stale); set == nil {
+		return
+	}
+
+	changed := prev == nil || prev.Checksum != set.Checksum
+
+	if !missing && changed && len(p.opts.Snapshot) > 0 {
+		if err := writeSnapshot(p.opts.Snapshot, set); err != nil {
+			log.Printf("Failed to write snapshot %v", err)
+		}
+	}
+}
+
+// error reports config which was rejected
+func (p *platform) error(err error) {
+	log.Printf("Rejected config %v", err)
+
+	if p.opts.Errors == nil {
+		return
+	}
+
+	select {
+	case p.opts.Errors <- err:
+	default:
+	}
+}
+
+// values returns the validated values of the change set and
+// the change set with any values the schema set e.g. defaults
+// so they're kept in the snapshot and history
+func (p *platform) values(set *ChangeSet) (Values, *ChangeSet, error) {
+	vals, err := p.opts.Reader.Values(set)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.opts.Schema == nil {
+		return vals, set, nil
+	}
+
+	if err := p.opts.Schema.Validate(vals); err != nil {
+		return nil, nil, err
+	}
+
+	b := vals.Bytes()
+	if bytes.Equal(b, set.Data) {
+		return vals, set, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, nil, err
+	}
+
+	h, err := hash.Hash(data, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return vals, &ChangeSet{
+		Timestamp: set.Timestamp,
+		Data:      b,
+		Checksum:  fmt.Sprintf("%x", h),
+		Source:    set.Source,
+		Format:    "json",
+	}, nil
+}
+
+// set updates the values and watchers with the merged change set.
+// Returns the change set applied, with any schema defaults, or nil
+// if it was rejected or we're pinned.
+func (p *platform) set(set *ChangeSet, stale bool) *ChangeSet {
+	vals, set, err := p.values(set)
+	if err != nil {
+		p.error(err)
+		return nil
+	}
+
+	p.Lock()
+	if p.pinned {
+		p.Unlock()
+		return nil
+	}
+	p.vals = vals
+	p.cset = set
+	p.stale = stale
+	p.Unlock()
+
+	// stale config isn't all from the sources so isn't a version
+	if !stale {
+		p.record(set)
+	}
+
+	p.update()
+
+	return set
+}
+
 func (p *platform) loaded() bool {
 	var loaded bool
 	p.RLock()
@@ -86,16 +396,18 @@ func (p *platform) update() {
 	var watchers []*watcher
 
 	p.RLock()
+	vals := p.vals
 	for _, w := range p.watchers {
 		watchers = append(watchers, w)
 	}
 	p.RUnlock()
 
+	if vals == nil {
+		return
+	}
+
 	for _, w := range watchers {
-		select {
-		case w.updates <- p.vals.Get(w.path...):
-		default:
-		}
+		w.push(vals.Get(w.path...))
 	}
 }
 
@@ -106,50 +418,34 @@ func (p *platform) sync() {
 		return
 	}
 
-	var sets []*ChangeSet
+	for i, source := range p.opts.Sources {
+		p.RLock()
+		prev := p.sets[i]
+		p.RUnlock()
 
-	for _, source := range p.opts.Sources {
 		ch, err := source.Read()
-		// should we actually skip failing sources?
-		// best effort merging right? but what if we
-		// already have good config? that would be screwed
+		// failing sources keep their last change set
+		// so we don't lose good config, if there is none
+		// we're best effort merging whatever we have
 		if err != nil {
-			p.RLock()
-			vals := p.vals
-			p.RUnlock()
-
-			// if we have no config, we're going to try
-			// load something
-			if vals == nil {
-				continue
-			} else {
-				return
-			}
+			continue
 		}
-		sets = append(sets, ch)
-	}
 
-	set, err := p.opts.Reader.Parse(sets...)
-	if err != nil {
-		log.Printf("Failed to parse ChangeSets %v", err)
-		return
+		p.Lock()
+		// unless a watcher applied a newer one during the read
+		if p.sets[i] == prev {
+			p.sets[i] = ch
+		}
+		p.Unlock()
 	}
 
-	p.Lock()
-	p.vals, _ = p.opts.Reader.Values(set)
-	p.cset = set
-	p.Unlock()
-
-	p.update()
+	p.merge()
 }
 
 func (p *platform) Close() error {
-	select {
-	case <-p.exit:
-		return nil
-	default:
+	p.once.Do(func() {
 		close(p.exit)
-	}
+	})
 	return nil
 }
 
@@ -237,6 +533,12 @@ func (p *platform) Bytes() []byte {
 	return p.vals.Bytes()
 }
 
+func (p *platform) Stale() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.stale
+}
+
 func (p *platform) Options() Options {
 	return p.opts
 }
@@ -250,11 +552,16 @@ func (p *platform) Watch(path ...string) (Watcher, error) {
 
 	p.Lock()
 
+	// the latest value so no change is missed
+	if p.vals != nil {
+		value = p.vals.Get(path...)
+	}
+
 	w := &watcher{
-		exit:    make(chan bool),
-		path:    path,
-		value:   value,
-		updates: make(chan Value, 1),
+		exit:   make(chan bool),
+		path:   path,
+		value:  value,
+		notify: make(chan bool, 1),
 	}
 
 	id := p.idx
@@ -273,26 +580,83 @@ func (p *platform) Watch(path ...string) (Watcher, error) {
 	return w, nil
 }
 
+// push queues the value for Next. Once the queue is full the
+// latest value replaces the last so a watcher which isn't read
+// doesn't grow forever. The change is diffed against the value
+// last returned so no changed paths are missed.
+func (w *watcher) push(v Value) {
+	w.Lock()
+	if n := len(w.queue); n >= maxQueue {
+		w.queue[n-1] = v
+	} else {
+		w.queue = append(w.queue, v)
+	}
+	w.Unlock()
+
+	select {
+	case w.notify <- true:
+	default:
+	}
+}
+
+// pop returns the next queued value if there is one
+func (w *watcher) pop() (Value, bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	if len(w.queue) == 0 {
+		return nil, false
+	}
+
+	v := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	return v, true
+}
+
 func (w *watcher) Next() (Value, error) {
+	c, err := w.NextChange()
+	if err != nil {
+		return nil, err
+	}
+	return c.New, nil
+}
+
+func (w *watcher) NextChange() (*Change, error) {
 	for {
 		select {
 		case <-w.exit:
 			return nil, errors.New("watcher stopped")
-		case v := <-w.updates:
-			if bytes.Equal(w.value.Bytes(), v.Bytes()) {
-				continue
+		default:
+		}
+
+		v, ok := w.pop()
+		if !ok {
+			select {
+			case <-w.exit:
+			case <-w.notify:
 			}
-			w.value = v
-			return v, nil
+			continue
+		}
+
+		if bytes.Equal(w.value.Bytes(), v.Bytes()) {
+			continue
 		}
+
+		c := &Change{
+			Old:  w.value,
+			New:  v,
+			Diff: diffValues(w.value, v),
+		}
+
+		w.value = v
+		return c, nil
 	}
 }
 
 func (w *watcher) Stop() error {
-	select {
-	case <-w.exit:
-	default:
+	w.once.Do(func() {
 		close(w.exit)
-	}
+	})
 	return nil
 }
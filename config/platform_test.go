@@ -0,0 +1,611 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-os/config"
+	"github.com/micro/go-os/config/source/memory"
+)
+
+// waitWatched waits for config to watch the source
+func waitWatched(t *testing.T, src *memory.Source) {
+	for i := 0; ; i++ {
+		src.RLock()
+		n := len(src.Watchers)
+		src.RUnlock()
+		if n > 0 {
+			return
+		}
+		if i > 100 {
+			t.Fatal("Source is not being watched")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestWatchSource(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{"foo": "bar"}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	w, err := c.Watch("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected bar got %s", v)
+	}
+
+	waitWatched(t, src)
+
+	src.Update([]byte(`{"foo": "baz"}`))
+
+	ch := make(chan config.Value, 1)
+	go func() {
+		v, err := w.Next()
+		if err != nil {
+			return
+		}
+		ch <- v
+	}()
+
+	select {
+	case v := <-ch:
+		if s := v.String(""); s != "baz" {
+			t.Fatalf("Expected baz got %s", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for update")
+	}
+}
+
+type errSource struct{}
+
+func (e *errSource) Read() (*config.ChangeSet, error) {
+	return nil, errors.New("unreachable")
+}
+
+func (e *errSource) Watch() (config.SourceWatcher, error) {
+	return nil, errors.New("unreachable")
+}
+
+func (e *errSource) String() string {
+	return "error"
+}
+
+// waitVersions waits for the history to reach n versions
+func waitVersions(t *testing.T, c config.Config, n int) []*config.Version {
+	for i := 0; ; i++ {
+		if v := c.Versions(); len(v) >= n {
+			return v
+		}
+		if i > 100 {
+			t.Fatalf("Expected %d versions", n)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// orderSource records the order it's watched and read
+type orderSource struct {
+	sync.Mutex
+	calls []string
+}
+
+func (o *orderSource) call(name string) {
+	o.Lock()
+	o.calls = append(o.calls, name)
+	o.Unlock()
+}
+
+func (o *orderSource) Read() (*config.ChangeSet, error) {
+	o.call("read")
+	return nil, errors.New("unreachable")
+}
+
+func (o *orderSource) Watch() (config.SourceWatcher, error) {
+	o.call("watch")
+	return nil, errors.New("unreachable")
+}
+
+func (o *orderSource) String() string {
+	return "order"
+}
+
+func TestWatchBeforeRead(t *testing.T) {
+	src := &orderSource{}
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	for i := 0; ; i++ {
+		src.Lock()
+		calls := src.calls
+		src.Unlock()
+
+		if len(calls) >= 2 {
+			// so changes during the read aren't missed
+			if calls[0] != "watch" || calls[1] != "read" {
+				t.Fatalf("Expected watch then read got %v", calls)
+			}
+			return
+		}
+
+		if i > 100 {
+			t.Fatalf("Expected watch then read got %v", calls)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	file := filepath.Join(os.TempDir(), fmt.Sprintf("config.snapshot.%d", time.Now().UnixNano()))
+	defer os.Remove(file)
+
+	c := config.NewConfig(
+		config.WithSource(memory.NewSource(memory.Data([]byte(`{"foo": "bar"}`)))),
+		config.Snapshot(file),
+	)
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected bar got %s", v)
+	}
+
+	if c.Stale() {
+		t.Fatal("Expected config not to be stale")
+	}
+
+	c.Close()
+
+	// sources are down, load the snapshot
+	c = config.NewConfig(
+		config.WithSource(&errSource{}),
+		config.Snapshot(file),
+	)
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected bar from snapshot got %s", v)
+	}
+
+	if !c.Stale() {
+		t.Fatal("Expected config to be stale")
+	}
+
+	c.Close()
+
+	// one source is down, changes from the rest still apply
+	src := memory.NewSource(memory.Data([]byte(`{"baz": "a"}`)))
+
+	c = config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(&errSource{}),
+		config.WithSource(src),
+		config.Snapshot(file),
+	)
+	defer c.Close()
+
+	waitWatched(t, src)
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected bar from snapshot got %s", v)
+	}
+
+	src.Update([]byte(`{"baz": "b"}`))
+
+	for i := 0; c.Get("baz").String("") != "b"; i++ {
+		if i == 100 {
+			t.Fatalf("Expected b got %s", c.Get("baz").String(""))
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected bar from snapshot got %s", v)
+	}
+}
+
+func TestSchemaReject(t *testing.T) {
+	type schema struct {
+		Port int `config:"port,required" max:"65535"`
+	}
+
+	src := memory.NewSource(memory.Data([]byte(`{"port": 8080}`)))
+	errs := make(chan error, 1)
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+		config.WithSchema(config.NewSchema(schema{})),
+		config.Errors(errs),
+	)
+	defer c.Close()
+
+	if v := c.Get("port").Int(0); v != 8080 {
+		t.Fatalf("Expected 8080 got %d", v)
+	}
+
+	waitWatched(t, src)
+
+	src.Update([]byte(`{"port": 80800}`))
+
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for error")
+	}
+
+	// previous values are kept
+	if v := c.Get("port").Int(0); v != 8080 {
+		t.Fatalf("Expected 8080 got %d", v)
+	}
+}
+
+func TestSchemaDefaults(t *testing.T) {
+	type schema struct {
+		Host string `config:"host" default:"localhost"`
+		Port int    `config:"port"`
+	}
+
+	file := filepath.Join(os.TempDir(), fmt.Sprintf("config.snapshot.%d", time.Now().UnixNano()))
+	defer os.Remove(file)
+
+	src := memory.NewSource(memory.Data([]byte(`{"port": 8080}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+		config.WithSchema(config.NewSchema(schema{})),
+		config.Snapshot(file),
+	)
+	defer c.Close()
+
+	if v := c.Get("host").String(""); v != "localhost" {
+		t.Fatalf("Expected localhost got %s", v)
+	}
+
+	// defaults are part of the version
+	versions := c.Versions()
+	if len(versions) != 1 || !strings.Contains(string(versions[0].ChangeSet.Data), "localhost") {
+		t.Fatalf("Expected version with the default got %+v", versions)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snap *config.ChangeSet
+	if err := json.Unmarshal(b, &snap); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(snap.Data), "localhost") {
+		t.Fatalf("Expected snapshot with the default got %s", snap.Data)
+	}
+
+	waitWatched(t, src)
+	src.Update([]byte(`{"port": 9090}`))
+	waitVersions(t, c, 2)
+
+	// rolled back with the default
+	if err := c.Rollback(versions[0].ChangeSet.Checksum); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := c.Get("port").Int(0); v != 8080 {
+		t.Fatalf("Expected 8080 got %d", v)
+	}
+
+	if b := c.Bytes(); !strings.Contains(string(b), "localhost") {
+		t.Fatalf("Expected the default after rollback got %s", b)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{"foo": "bar"}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected bar got %s", v)
+	}
+
+	waitWatched(t, src)
+
+	src.Update([]byte(`{"foo": "baz", "bar": 1}`))
+	versions := waitVersions(t, c, 2)
+
+	d, err := config.Compare(versions[1].ChangeSet, versions[0].ChangeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Added) != 1 || d.Added[0][0] != "bar" || len(d.Modified) != 1 || d.Modified[0][0] != "foo" || len(d.Removed) != 0 {
+		t.Fatalf("Unexpected diff %+v", d)
+	}
+
+	if err := c.Rollback(versions[1].ChangeSet.Checksum); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected bar after rollback got %s", v)
+	}
+
+	// pinned so changes are neither applied nor recorded
+	src.Update([]byte(`{"foo": "qux"}`))
+	time.Sleep(time.Millisecond * 50)
+
+	if v := c.Get("foo").String(""); v != "bar" {
+		t.Fatalf("Expected pinned bar got %s", v)
+	}
+
+	if v := c.Versions(); len(v) != 2 {
+		t.Fatalf("Expected 2 versions while pinned got %d", len(v))
+	}
+
+	if err := c.Rollback("unknown"); err == nil {
+		t.Fatal("Expected error for unknown version")
+	}
+
+	if err := c.Unpin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := c.Get("foo").String(""); v != "qux" {
+		t.Fatalf("Expected qux after unpin got %s", v)
+	}
+
+	waitVersions(t, c, 3)
+}
+
+func TestWatchChanges(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{"db": {"host": "a", "port": 1}}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	w, err := c.Watch("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	waitWatched(t, src)
+
+	// none are dropped while we aren't reading
+	updates := []string{
+		`{"db": {"host": "b", "port": 1}}`,
+		`{"db": {"host": "b", "port": 1, "user": "x"}}`,
+		`{"db": {"host": "b", "user": "x"}}`,
+	}
+
+	for _, u := range updates {
+		src.Update([]byte(u))
+	}
+
+	expected := []struct {
+		host  string
+		field string
+		paths func(d *config.Diff) [][]string
+	}{
+		{"b", "host", func(d *config.Diff) [][]string { return d.Modified }},
+		{"b", "user", func(d *config.Diff) [][]string { return d.Added }},
+		{"b", "port", func(d *config.Diff) [][]string { return d.Removed }},
+	}
+
+	for i, e := range expected {
+		ch := make(chan *config.Change, 1)
+		go func() {
+			c, err := w.NextChange()
+			if err != nil {
+				return
+			}
+			ch <- c
+		}()
+
+		var change *config.Change
+
+		select {
+		case change = <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for change %d", i)
+		}
+
+		var db struct {
+			Host string
+		}
+		if err := change.New.Scan(&db); err != nil || db.Host != e.host {
+			t.Fatalf("Expected host %s got %s %v", e.host, db.Host, err)
+		}
+
+		paths := e.paths(change.Diff)
+		if len(paths) != 1 || len(paths[0]) != 1 || paths[0][0] != e.field {
+			t.Fatalf("Change %d expected %s got %+v", i, e.field, change.Diff)
+		}
+
+		n := len(change.Diff.Added) + len(change.Diff.Removed) + len(change.Diff.Modified)
+		if n != 1 {
+			t.Fatalf("Change %d expected one path got %+v", i, change.Diff)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{"db": {"host": "localhost"}}`)))
+	env := memory.NewSource(memory.Data([]byte(`{"debug": true}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+		config.WithSource(env),
+		config.WithWriter(src),
+	)
+	defer c.Close()
+
+	err := c.Write(func(v config.Values) error {
+		v.Set(5432, "db", "port")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// applied straight away
+	if v := c.Get("db", "port").Int(0); v != 5432 {
+		t.Fatalf("Expected 5432 got %d", v)
+	}
+
+	// only the writer's values are written
+	ch, err := src.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(ch.Data); strings.Contains(s, "debug") || !strings.Contains(s, "5432") {
+		t.Fatalf("Unexpected data written %s", s)
+	}
+
+	// the source changed since it was read
+	src.Update([]byte(`{"db": {"host": "other"}}`))
+
+	if _, err := src.Write(ch, []byte(`{}`)); err != config.ErrConflict {
+		t.Fatalf("Expected conflict got %v", err)
+	}
+}
+
+func TestWatchUndrained(t *testing.T) {
+	src := memory.NewSource(memory.Data([]byte(`{"db": {"port": 0}}`)))
+
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(src),
+	)
+	defer c.Close()
+
+	w, err := c.Watch("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	waitWatched(t, src)
+
+	update := func(i int) {
+		src.Update([]byte(fmt.Sprintf(`{"db": {"port": %d, "user%d": "x"}}`, i, i)))
+	}
+
+	// pile up changes without reading them
+	updates := 500
+	for i := 1; i <= updates; i++ {
+		update(i)
+	}
+
+	// the memory source drops updates when busy so
+	// repeat the last until it's merged
+	for i := 0; c.Get("db", "port").Int(0) != updates; i++ {
+		if i == 100 {
+			t.Fatal("Timed out waiting for the last update")
+		}
+		update(updates)
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	var changes int
+
+	for {
+		ch := make(chan *config.Change, 1)
+		go func() {
+			c, err := w.NextChange()
+			if err != nil {
+				return
+			}
+			ch <- c
+		}()
+
+		var change *config.Change
+
+		select {
+		case change = <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for change %d", changes)
+		}
+
+		changes++
+
+		var db struct {
+			Port int
+		}
+		if err := change.New.Scan(&db); err != nil {
+			t.Fatal(err)
+		}
+		if db.Port < updates {
+			continue
+		}
+
+		// coalesced changes still have the paths
+		// changed since the last one returned
+		user := fmt.Sprintf("user%d", updates)
+		if len(change.Diff.Added) != 1 || change.Diff.Added[0][0] != user {
+			t.Fatalf("Expected %s added got %+v", user, change.Diff)
+		}
+		break
+	}
+
+	// at most 64 are queued
+	if changes > 64 {
+		t.Fatalf("Expected changes to be coalesced got %d", changes)
+	}
+}
+
+func TestCloseConcurrent(t *testing.T) {
+	c := config.NewConfig(
+		config.PollInterval(time.Hour),
+		config.WithSource(memory.NewSource()),
+	)
+
+	w, err := c.Watch("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// closing from many goroutines mustn't panic
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Stop()
+			c.Close()
+		}()
+	}
+	wg.Wait()
+
+	if _, err := w.Next(); err == nil {
+		t.Fatal("Expected error from a stopped watcher")
+	}
+}
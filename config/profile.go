@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/imdario/mergo"
+)
+
+// profile selects the base config and the overlay
+// for the environment from a source e.g.
+//
+//	base:
+//	  db:
+//	    host: localhost
+//	environments:
+//	  staging:
+//	    db:
+//	      host: staging
+//
+// Other top level keys are kept with the base. Sources
+// without base or environments are used as they are.
+func profile(data map[string]interface{}, env string) (map[string]interface{}, error) {
+	base, hasBase := data["base"]
+	envs, hasEnvs := data["environments"]
+
+	if !hasBase && !hasEnvs {
+		return data, nil
+	}
+
+	merged := make(map[string]interface{})
+	for k, v := range data {
+		if k != "base" && k != "environments" {
+			merged[k] = v
+		}
+	}
+
+	if hasBase {
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("base must be a map")
+		}
+		if err := mergo.MapWithOverwrite(&merged, b); err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasEnvs {
+		return merged, nil
+	}
+
+	e, ok := envs.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("environments must be a map")
+	}
+
+	overlay, ok := e[env]
+	if !ok {
+		return merged, nil
+	}
+
+	o, ok := overlay.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("environments.%s must be a map", env)
+	}
+
+	if err := mergo.MapWithOverwrite(&merged, o); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
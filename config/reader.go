@@ -7,28 +7,68 @@ import (
 	"time"
 
 	"github.com/imdario/mergo"
+	"github.com/micro/go-os/config/encoder"
+	"github.com/micro/go-os/config/encoder/hcl"
+	ejson "github.com/micro/go-os/config/encoder/json"
+	"github.com/micro/go-os/config/encoder/properties"
+	"github.com/micro/go-os/config/encoder/toml"
+	"github.com/micro/go-os/config/encoder/yaml"
 	hash "github.com/mitchellh/hashstructure"
 )
 
-type jsonReader struct{}
+// jsonReader decodes ChangeSets of any format it has an
+// encoder for and merges them down to a json ChangeSet
+type jsonReader struct {
+	opts ReaderOptions
+}
 
 func (j *jsonReader) Parse(changes ...*ChangeSet) (*ChangeSet, error) {
 	var merged map[string]interface{}
 
 	for _, m := range changes {
 		if len(m.Data) == 0 {
-			m.Data = []byte(`{}`)
+			continue
+		}
+
+		format := m.Format
+		if len(format) == 0 {
+			format = DefaultFormat
+		}
+
+		enc, ok := j.opts.Encoding[format]
+		if !ok {
+			return nil, fmt.Errorf("unsupported format %s from %s", format, m.Source)
 		}
 
 		var data map[string]interface{}
-		if err := json.Unmarshal(m.Data, &data); err != nil {
+		if err := enc.Decode(m.Data, &data); err != nil {
 			return nil, err
 		}
+
+		if len(j.opts.Environment) > 0 {
+			p, err := profile(data, j.opts.Environment)
+			if err != nil {
+				return nil, err
+			}
+			data = p
+		}
 		if err := mergo.MapWithOverwrite(&merged, data); err != nil {
 			return nil, err
 		}
 	}
 
+	if merged == nil {
+		merged = make(map[string]interface{})
+	}
+
+	// references are resolved once everything is merged
+	// so they can point at values from any source
+	if j.opts.Interpolate {
+		if err := interpolate(merged); err != nil {
+			return nil, err
+		}
+	}
+
 	b, err := json.Marshal(merged)
 	if err != nil {
 		return nil, err
@@ -44,6 +84,7 @@ func (j *jsonReader) Parse(changes ...*ChangeSet) (*ChangeSet, error) {
 		Data:      b,
 		Checksum:  fmt.Sprintf("%x", h),
 		Source:    "json",
+		Format:    "json",
 	}, nil
 }
 
@@ -58,7 +99,31 @@ func (j *jsonReader) String() string {
 	return "json"
 }
 
-// New json reader
-func NewReader() Reader {
-	return &jsonReader{}
+// encoders returns the supported encoders by format
+func encoders() map[string]encoder.Encoder {
+	m := make(map[string]encoder.Encoder)
+	for _, e := range []encoder.Encoder{
+		ejson.NewEncoder(),
+		yaml.NewEncoder(),
+		toml.NewEncoder(),
+		hcl.NewEncoder(),
+		properties.NewEncoder(),
+	} {
+		m[e.String()] = e
+	}
+	return m
+}
+
+// New json reader. Reads json, yaml, toml, hcl and
+// properties by default, more can be added as options.
+func NewReader(opts ...ReaderOption) Reader {
+	options := ReaderOptions{
+		Encoding: encoders(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &jsonReader{opts: options}
 }
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -39,3 +41,219 @@ func TestReader(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderFormats(t *testing.T) {
+	changes := []*ChangeSet{
+		{
+			Data:   []byte(`{"foo": "bar", "db": {"host": "localhost", "port": 5432}}`),
+			Format: "json",
+		},
+		{
+			Data:   []byte("db:\n  host: yaml.host\n  tags: [a, b]\n"),
+			Format: "yaml",
+		},
+		{
+			Data:   []byte("[cache]\nttl = \"1m\"\nsize = 10\n"),
+			Format: "toml",
+		},
+		{
+			Data:   []byte("queue {\n  name = \"jobs\"\n}\n"),
+			Format: "hcl",
+		},
+		{
+			Data:   []byte("queue.workers=4\nfoo=baz\n"),
+			Format: "properties",
+		},
+	}
+
+	r := NewReader()
+
+	c, err := r.Parse(changes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := r.Values(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		path  []string
+		value string
+	}{
+		{[]string{"foo"}, "baz"},
+		{[]string{"db", "host"}, "yaml.host"},
+		{[]string{"cache", "ttl"}, "1m"},
+		{[]string{"queue", "name"}, "jobs"},
+		{[]string{"queue", "workers"}, "4"},
+	}
+
+	for _, test := range testData {
+		if v := values.Get(test.path...).String(""); v != test.value {
+			t.Fatalf("Expected %s got %s for path %v", test.value, v, test.path)
+		}
+	}
+
+	if v := values.Get("db", "port").Int(0); v != 5432 {
+		t.Fatalf("Expected 5432 got %d", v)
+	}
+
+	if v := values.Get("db", "tags").StringSlice(nil); len(v) != 2 {
+		t.Fatalf("Expected 2 tags got %v", v)
+	}
+
+	if v := values.Get("cache", "size").Int(0); v != 10 {
+		t.Fatalf("Expected 10 got %d", v)
+	}
+
+	if _, err := r.Parse(&ChangeSet{Data: []byte("foo"), Format: "ini"}); err == nil {
+		t.Fatal("Expected error for unsupported format")
+	}
+}
+
+func TestReaderInterpolate(t *testing.T) {
+	os.Setenv("TEST_DB_USER", "admin")
+	defer os.Unsetenv("TEST_DB_USER")
+
+	changes := []*ChangeSet{
+		{Data: []byte(`{"db": {"host": "localhost", "port": 5432}, "api": {"db": "${db}"}}`)},
+		{Data: []byte(`{
+			"db": {"host": "prod", "user": "${env:TEST_DB_USER}", "pass": "${env:TEST_DB_PASS:-none}"},
+			"url": "postgres://${db.user}@${db.host}:${db.port}",
+			"literal": "$${db.host}",
+			"hosts": ["${db.host}", "other"]
+		}`)},
+	}
+
+	r := NewReader(ReaderInterpolate(true))
+
+	c, err := r.Parse(changes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := r.Values(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		path  []string
+		value string
+	}{
+		{[]string{"url"}, "postgres://admin@prod:5432"},
+		{[]string{"db", "pass"}, "none"},
+		{[]string{"literal"}, "${db.host}"},
+		// whole values keep their type
+		{[]string{"api", "db", "host"}, "prod"},
+	}
+
+	for _, test := range testData {
+		if v := values.Get(test.path...).String(""); v != test.value {
+			t.Fatalf("Expected %s got %s for path %v", test.value, v, test.path)
+		}
+	}
+
+	if v := values.Get("api", "db", "port").Int(0); v != 5432 {
+		t.Fatalf("Expected 5432 got %d", v)
+	}
+
+	if v := values.Get("hosts").StringSlice(nil); len(v) != 2 || v[0] != "prod" {
+		t.Fatalf("Expected prod got %v", v)
+	}
+
+	// off by default so literal references still load
+	c, err = NewReader().Parse(&ChangeSet{Data: []byte(`{"greeting": "Hello ${name}"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := r.Values(c); values.Get("greeting").String("") != "Hello ${name}" {
+		t.Fatalf("Expected Hello ${name} got %s", values.Get("greeting").String(""))
+	}
+
+	errData := []struct {
+		data string
+		err  string
+	}{
+		{`{"a": "${b}", "b": "${c}", "c": "${a}"}`, "reference cycle"},
+		{`{"a": {"b": "${a}"}}`, "reference cycle"},
+		{`{"a": "${missing.key}"}`, "unresolved reference ${missing.key}"},
+		{`{"a": "${env:TEST_CONFIG_UNSET}"}`, "unresolved reference ${env:TEST_CONFIG_UNSET}"},
+		{`{"a": {"b": 1}, "c": "x${a}"}`, "can't embed ${a}"},
+	}
+
+	for _, d := range errData {
+		_, err := r.Parse(&ChangeSet{Data: []byte(d.data)})
+		if err == nil || !strings.Contains(err.Error(), d.err) {
+			t.Fatalf("Expected %s for %s got %v", d.err, d.data, err)
+		}
+	}
+}
+
+func TestReaderEnvironment(t *testing.T) {
+	changes := []*ChangeSet{
+		{
+			Format: "yaml",
+			Data: []byte(`
+name: api
+base:
+  db:
+    host: localhost
+    port: 5432
+environments:
+  staging:
+    db:
+      host: staging
+  production:
+    db:
+      host: production
+`),
+		},
+		// sources without profiles are used as they are
+		{Data: []byte(`{"debug": true}`)},
+	}
+
+	testData := []struct {
+		env  string
+		host string
+	}{
+		{"staging", "staging"},
+		{"production", "production"},
+		{"dev", "localhost"},
+	}
+
+	for _, d := range testData {
+		r := NewReader(ReaderEnvironment(d.env))
+
+		c, err := r.Parse(changes...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values, err := r.Values(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v := values.Get("db", "host").String(""); v != d.host {
+			t.Fatalf("Expected %s got %s for %s", d.host, v, d.env)
+		}
+
+		if v := values.Get("db", "port").Int(0); v != 5432 {
+			t.Fatalf("Expected base port 5432 got %d for %s", v, d.env)
+		}
+
+		if v := values.Get("name").String(""); v != "api" {
+			t.Fatalf("Expected api got %s for %s", v, d.env)
+		}
+
+		if v := values.Get("debug").Bool(false); !v {
+			t.Fatalf("Expected debug for %s", d.env)
+		}
+
+		if v := values.Get("environments").Bytes(); string(v) != "null" && len(v) > 0 {
+			t.Fatalf("Expected environments to be removed got %s", v)
+		}
+	}
+}
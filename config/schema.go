@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// structSchema validates values against the fields of a struct
+type structSchema struct {
+	fields []*field
+}
+
+func newSchema(v interface{}) Schema {
+	return &structSchema{
+		fields: fields(reflect.TypeOf(v), nil, nil),
+	}
+}
+
+// bound parses a min or max tag. Durations are
+// compared in nanoseconds and everything else
+// which isn't a number by length.
+func (f *field) bound(s string) (float64, error) {
+	if f.typ == durationType {
+		d, err := time.ParseDuration(s)
+		return float64(d), err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// check returns an error if v isn't valid for the field
+func (f *field) check(v interface{}) error {
+	var n float64
+
+	switch k := f.typ.Kind(); {
+	case f.typ == durationType:
+		s, ok := v.(string)
+		if !ok {
+			return errors.New("must be a duration")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		n = float64(d)
+	case k == reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return errors.New("must be a string")
+		}
+		n = float64(len(s))
+	case k == reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			return errors.New("must be a bool")
+		}
+	case k >= reflect.Int && k <= reflect.Uint64:
+		i, ok := v.(float64)
+		if !ok || i != math.Trunc(i) {
+			return errors.New("must be an integer")
+		}
+		if k >= reflect.Uint && i < 0 {
+			return errors.New("must be positive")
+		}
+		n = i
+	case k == reflect.Float32 || k == reflect.Float64:
+		i, ok := v.(float64)
+		if !ok {
+			return errors.New("must be a number")
+		}
+		n = i
+	case k == reflect.Slice:
+		s, ok := v.([]interface{})
+		if !ok {
+			return errors.New("must be a list")
+		}
+		n = float64(len(s))
+	case k == reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return errors.New("must be a map")
+		}
+		n = float64(len(m))
+	default:
+		return nil
+	}
+
+	if len(f.min) > 0 {
+		min, err := f.bound(f.min)
+		if err != nil {
+			return fmt.Errorf("bad min %v", err)
+		}
+		if n < min {
+			return fmt.Errorf("must be at least %s", f.min)
+		}
+	}
+
+	if len(f.max) > 0 {
+		max, err := f.bound(f.max)
+		if err != nil {
+			return fmt.Errorf("bad max %v", err)
+		}
+		if n > max {
+			return fmt.Errorf("must be at most %s", f.max)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks every field and sets defaults for missing ones
+func (s *structSchema) Validate(vals Values) error {
+	var errs []string
+
+	for _, f := range s.fields {
+		key := strings.Join(f.path, ".")
+
+		var v interface{}
+		vals.Get(f.path...).Scan(&v)
+
+		if v != nil {
+			if err := f.check(v); err != nil {
+				errs = append(errs, fmt.Sprintf("%s %v", key, err))
+			}
+			continue
+		}
+
+		if len(f.def) > 0 {
+			d, err := f.parse(f.def)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s bad default %v", key, err))
+				continue
+			}
+			vals.Set(d, f.path...)
+			continue
+		}
+
+		if f.required {
+			errs = append(errs, fmt.Sprintf("%s is required", key))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (s *structSchema) String() string {
+	return "struct"
+}
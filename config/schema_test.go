@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+type testDB struct {
+	Host    string        `config:"host,required"`
+	Port    int           `config:"port" default:"5432" min:"1" max:"65535"`
+	Timeout time.Duration `config:"timeout" default:"5s" max:"1m"`
+	Tags    []string      `config:"tags" max:"2"`
+}
+
+type testSchema struct {
+	Name string `config:"name" min:"3"`
+	DB   testDB `config:"db"`
+}
+
+func TestSchema(t *testing.T) {
+	testData := []struct {
+		data  string
+		valid bool
+	}{
+		{`{"name": "foo", "db": {"host": "localhost"}}`, true},
+		{`{"name": "foo", "db": {"host": "localhost", "port": 3306, "tags": ["a"]}}`, true},
+		// missing required
+		{`{"name": "foo", "db": {"hots": "localhost"}}`, false},
+		// wrong types
+		{`{"db": {"host": "localhost", "port": "abc"}}`, false},
+		{`{"db": {"host": "localhost", "port": 1.5}}`, false},
+		{`{"db": {"host": "localhost", "timeout": "soon"}}`, false},
+		// out of range
+		{`{"db": {"host": "localhost", "port": 70000}}`, false},
+		{`{"db": {"host": "localhost", "timeout": "1h"}}`, false},
+		{`{"name": "fo", "db": {"host": "localhost"}}`, false},
+		{`{"db": {"host": "localhost", "tags": ["a", "b", "c"]}}`, false},
+	}
+
+	s := NewSchema(testSchema{})
+
+	for _, d := range testData {
+		vals, err := newValues(&ChangeSet{Data: []byte(d.data)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.Validate(vals)
+		if d.valid && err != nil {
+			t.Fatalf("Expected %s to be valid got %v", d.data, err)
+		}
+		if !d.valid && err == nil {
+			t.Fatalf("Expected %s to be invalid", d.data)
+		}
+	}
+}
+
+func TestSchemaDefaults(t *testing.T) {
+	vals, err := newValues(&ChangeSet{Data: []byte(`{"db": {"host": "localhost"}}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewSchema(testSchema{}).Validate(vals); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := vals.Get("db", "port").Int(0); v != 5432 {
+		t.Fatalf("Expected default port 5432 got %d", v)
+	}
+
+	if v := vals.Get("db", "timeout").Duration(0); v != time.Second*5 {
+		t.Fatalf("Expected default timeout 5s got %v", v)
+	}
+}
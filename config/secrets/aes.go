@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+type aesCipher struct {
+	aead cipher.AEAD
+	// error creating the cipher
+	err error
+}
+
+func newAESCipher(key []byte) *aesCipher {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return &aesCipher{err: err}
+	}
+	aead, err := cipher.NewGCM(b)
+	if err != nil {
+		return &aesCipher{err: err}
+	}
+	return &aesCipher{aead: aead}
+}
+
+// Encrypt returns the nonce followed by the ciphertext
+func (a *aesCipher) Encrypt(b []byte) ([]byte, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, b, nil), nil
+}
+
+func (a *aesCipher) Decrypt(b []byte) ([]byte, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	n := a.aead.NonceSize()
+	if len(b) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return a.aead.Open(nil, b[:n], b[n:], nil)
+}
+
+func (a *aesCipher) String() string {
+	return "aes-gcm"
+}
+
+// NewAESCipher is an AES-GCM cipher. The key must be 16, 24
+// or 32 bytes for AES-128, AES-192 or AES-256.
+func NewAESCipher(key []byte) Cipher {
+	return newAESCipher(key)
+}
+
+// validKey is true for AES-128, AES-192 and AES-256 key sizes
+func validKey(b []byte) bool {
+	switch len(b) {
+	case 16, 24, 32:
+		return true
+	}
+	return false
+}
+
+// NewKeyFile is an AES-GCM cipher with the key read from the file.
+// A key of 16, 24 or 32 bytes is used as is, binary keys included.
+// Otherwise surrounding whitespace is trimmed and it's used as is
+// if that's a valid size or base64 decoded. Prefix the key with
+// base64: to always decode it.
+func NewKeyFile(file string) Cipher {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return &aesCipher{err: err}
+	}
+
+	t := bytes.TrimSpace(b)
+
+	if bytes.HasPrefix(t, []byte("base64:")) {
+		key, err := base64.StdEncoding.DecodeString(string(t[7:]))
+		if err != nil {
+			return &aesCipher{err: err}
+		}
+		return newAESCipher(key)
+	}
+
+	// whitespace may be part of a binary key
+	if validKey(b) {
+		return newAESCipher(b)
+	}
+
+	// a key written with a trailing newline
+	if validKey(t) {
+		return newAESCipher(t)
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(string(t)); err == nil && validKey(key) {
+		t = key
+	}
+
+	return newAESCipher(t)
+}
@@ -0,0 +1,112 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/micro/go-os/config"
+)
+
+type reader struct {
+	r config.Reader
+	c Cipher
+}
+
+type values struct {
+	config.Values
+	r *reader
+}
+
+// decrypt walks the value decrypting any envelopes
+func (r *reader) decrypt(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		if !IsEncrypted(t) {
+			return t, nil
+		}
+		return Decrypt(r.c, t)
+	case map[string]interface{}:
+		for k, val := range t {
+			d, err := r.decrypt(val)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = d
+		}
+		return t, nil
+	case []interface{}:
+		for i, val := range t {
+			d, err := r.decrypt(val)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = d
+		}
+		return t, nil
+	}
+	return v, nil
+}
+
+// Parse merges the change sets and checks every secret can be
+// decrypted. The merged change set keeps the secrets encrypted.
+func (r *reader) Parse(changes ...*config.ChangeSet) (*config.ChangeSet, error) {
+	ch, err := r.r.Parse(changes...)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(ch.Data, &v); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.decrypt(v); err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+func (r *reader) Values(ch *config.ChangeSet) (config.Values, error) {
+	vals, err := r.r.Values(ch)
+	if err != nil {
+		return nil, err
+	}
+	return &values{vals, r}, nil
+}
+
+func (r *reader) String() string {
+	return r.r.String()
+}
+
+// Get returns the value with secrets decrypted
+func (v *values) Get(path ...string) config.Value {
+	val := v.Values.Get(path...)
+
+	// nothing to decrypt
+	if !bytes.Contains(val.Bytes(), []byte(Prefix)) {
+		return val
+	}
+
+	var i interface{}
+	if err := val.Scan(&i); err != nil {
+		return val
+	}
+
+	d, err := v.r.decrypt(i)
+	if err != nil {
+		return val
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"v": d})
+	if err != nil {
+		return val
+	}
+
+	dv, err := v.r.r.Values(&config.ChangeSet{Data: b})
+	if err != nil {
+		return val
+	}
+
+	return dv.Get("v")
+}
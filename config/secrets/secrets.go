@@ -0,0 +1,63 @@
+// Package secrets decrypts secret config values. Secrets are
+// stored in sources as envelopes e.g. enc:v1:<base64> and only
+// decrypted when read with Get so they stay out of Bytes.
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/micro/go-os/config"
+)
+
+// Cipher encrypts and decrypts secrets e.g. AES-GCM
+type Cipher interface {
+	Encrypt([]byte) ([]byte, error)
+	Decrypt([]byte) ([]byte, error)
+	String() string
+}
+
+var (
+	// Prefix of an encrypted value
+	Prefix = "enc:v1:"
+
+	ErrNotEncrypted = errors.New("value is not encrypted")
+)
+
+// IsEncrypted returns true if the value is an envelope
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Encrypt returns an envelope for the value which can be stored in a source
+func Encrypt(c Cipher, value string) (string, error) {
+	b, err := c.Encrypt([]byte(value))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Decrypt returns the value in the envelope
+func Decrypt(c Cipher, envelope string) (string, error) {
+	if !IsEncrypted(envelope) {
+		return "", ErrNotEncrypted
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(envelope, Prefix))
+	if err != nil {
+		return "", err
+	}
+	v, err := c.Decrypt(b)
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
+
+// NewReader wraps the reader to decrypt secrets. Parse fails if
+// a secret can't be decrypted. Values returned by Get are
+// decrypted while Bytes keeps secrets encrypted.
+func NewReader(r config.Reader, c Cipher) config.Reader {
+	return &reader{r, c}
+}
@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro/go-os/config"
+	"github.com/micro/go-os/config/source/memory"
+)
+
+func TestSecrets(t *testing.T) {
+	c := NewAESCipher([]byte("0123456789abcdef0123456789abcdef"))
+
+	env, err := Encrypt(c, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncrypted(env) {
+		t.Fatalf("Expected envelope got %s", env)
+	}
+
+	data := []byte(fmt.Sprintf(`{"db": {"user": "admin", "password": "%s"}}`, env))
+
+	conf := config.NewConfig(
+		config.WithReader(NewReader(config.NewReader(), c)),
+		config.WithSource(memory.NewSource(memory.Data(data))),
+	)
+	defer conf.Close()
+
+	if v := conf.Get("db", "password").String(""); v != "s3cret" {
+		t.Fatalf("Expected s3cret got %s", v)
+	}
+
+	var db map[string]string
+	if err := conf.Get("db").Scan(&db); err != nil {
+		t.Fatal(err)
+	}
+
+	if db["password"] != "s3cret" || db["user"] != "admin" {
+		t.Fatalf("Unexpected values %v", db)
+	}
+
+	if b := conf.Bytes(); bytes.Contains(b, []byte("s3cret")) || !bytes.Contains(b, []byte(env)) {
+		t.Fatalf("Expected secrets to be encrypted in %s", b)
+	}
+}
+
+func TestSecretsWrongKey(t *testing.T) {
+	env, err := Encrypt(NewAESCipher([]byte("0123456789abcdef")), "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(config.NewReader(), NewAESCipher([]byte("fedcba9876543210")))
+
+	_, err = r.Parse(&config.ChangeSet{Data: []byte(fmt.Sprintf(`{"password": "%s"}`, env))})
+	if err == nil {
+		t.Fatal("Expected error decrypting with the wrong key")
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// raw keys made of base64 characters are used as is
+	raw := []byte("abcdefghijklmnopqrstuvwxyz012345")
+	short := []byte("0123456789abcdef")
+
+	// binary keys ending in whitespace
+	newline := append([]byte("0123456789abcdef0123456789abcde"), '\n')
+	space := append([]byte("0123456789abcdef0123456789abcde"), ' ')
+
+	testData := []struct {
+		file string
+		key  []byte
+	}{
+		{string(raw), raw},
+		{string(newline), newline},
+		{string(space), space},
+		{string(raw) + "\n", raw},
+		{base64.StdEncoding.EncodeToString(raw) + "\n", raw},
+		{"base64:" + base64.StdEncoding.EncodeToString(short) + "\n", short},
+	}
+
+	for i, d := range testData {
+		file := filepath.Join(dir, fmt.Sprintf("key-%d", i))
+		if err := ioutil.WriteFile(file, []byte(d.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		env, err := Encrypt(NewKeyFile(file), "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// decrypts with the expected key
+		v, err := Decrypt(NewAESCipher(d.key), env)
+		if err != nil {
+			t.Fatalf("Expected key %s for %s got %v", d.key, d.file, err)
+		}
+		if v != "s3cret" {
+			t.Fatalf("Expected s3cret got %s", v)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// readSnapshot loads the last good merged change set
+func readSnapshot(file string) (*ChangeSet, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var ch *ChangeSet
+	if err := json.Unmarshal(b, &ch); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// writeSnapshot saves the change set
+func writeSnapshot(file string, ch *ChangeSet) error {
+	return writeFile(file, ch)
+}
+
+// writeFile saves v as json. It's written to a temp file
+// and renamed so a crash never leaves it half written.
+func writeFile(file string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
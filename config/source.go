@@ -16,7 +16,8 @@ type source struct {
 
 func (s *source) Read() (*ChangeSet, error) {
 	rsp, err := s.client.Read(context.TODO(), &proto.ReadRequest{
-		Id: s.opts.Name,
+		Id:   s.opts.Name,
+		Path: s.opts.Namespace,
 	})
 	if err != nil {
 		return nil, err
@@ -26,6 +27,7 @@ func (s *source) Read() (*ChangeSet, error) {
 		Data:      []byte(rsp.Change.ChangeSet.Data),
 		Checksum:  rsp.Change.ChangeSet.Checksum,
 		Source:    rsp.Change.ChangeSet.Source,
+		Format:    s.opts.Format,
 	}, nil
 }
 
@@ -35,12 +37,13 @@ func (s *source) String() string {
 
 func (s *source) Watch() (SourceWatcher, error) {
 	stream, err := s.client.Watch(context.TODO(), &proto.WatchRequest{
-		Id: s.opts.Name,
+		Id:   s.opts.Name,
+		Path: s.opts.Namespace,
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &sourceWatcher{stream}, nil
+	return &sourceWatcher{stream, s.opts.Format}, nil
 }
 
 func NewSource(opts ...SourceOption) Source {
@@ -27,7 +27,7 @@ func (c *consul) Read() (*config.ChangeSet, error) {
 	}
 
 	if kv == nil {
-		return nil, fmt.Errorf("source not found: %s", c.opts.Name)
+		return nil, config.ErrNotFound
 	}
 
 	// hash the consul
@@ -39,22 +39,75 @@ func (c *consul) Read() (*config.ChangeSet, error) {
 		Source:   c.String(),
 		Data:     kv.Value,
 		Checksum: checksum,
+		Format:   c.opts.Format,
 	}, nil
 }
 
+// Write puts the data if the checksum of the key matches
+// prev, using the modify index for check and set
+func (c *consul) Write(prev *config.ChangeSet, data []byte) (*config.ChangeSet, error) {
+	kv := c.client.KV()
+	p := &api.KVPair{Key: c.opts.Name, Value: data}
+
+	if prev == nil {
+		if _, err := kv.Put(p, nil); err != nil {
+			return nil, err
+		}
+		return c.Read()
+	}
+
+	cur, _, err := kv.Get(c.opts.Name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if cur == nil || checksum(cur.Value) != prev.Checksum {
+		return nil, config.ErrConflict
+	}
+
+	p.ModifyIndex = cur.ModifyIndex
+
+	ok, _, err := kv.CAS(p, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, config.ErrConflict
+	}
+
+	return &config.ChangeSet{
+		Source:   c.String(),
+		Data:     data,
+		Checksum: checksum(data),
+		Format:   c.opts.Format,
+	}, nil
+}
+
+// Format of the data written to the key
+func (c *consul) Format() string {
+	return c.opts.Format
+}
+
+func checksum(b []byte) string {
+	h := md5.New()
+	h.Write(b)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
 func (c *consul) String() string {
 	return "consul"
 }
 
 func (c *consul) Watch() (config.SourceWatcher, error) {
-	w, err := newWatcher(c.opts.Name, c.addr, c.String())
+	w, err := newWatcher(c.opts.Name, c.addr, c.String(), c.opts.Format)
 	if err != nil {
 		return nil, err
 	}
 	return w, nil
 }
 
-func NewSource(opts ...config.SourceOption) config.Source {
+func NewSource(opts ...config.SourceOption) config.WritableSource {
 	options := config.SourceOptions{
 		Name: DefaultPath,
 	}
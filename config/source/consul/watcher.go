@@ -11,18 +11,20 @@ import (
 )
 
 type watcher struct {
-	name string
+	name   string
+	format string
 
 	wp   *watch.WatchPlan
 	ch   chan *config.ChangeSet
 	exit chan bool
 }
 
-func newWatcher(key, addr, name string) (config.SourceWatcher, error) {
+func newWatcher(key, addr, name, format string) (config.SourceWatcher, error) {
 	w := &watcher{
-		name: name,
-		ch:   make(chan *config.ChangeSet),
-		exit: make(chan bool),
+		name:   name,
+		format: format,
+		ch:     make(chan *config.ChangeSet),
+		exit:   make(chan bool),
 	}
 
 	wp, err := watch.Parse(map[string]interface{}{"type": "key", "key": key})
@@ -59,6 +61,7 @@ func (w *watcher) handle(idx uint64, data interface{}) {
 		Source:   w.name,
 		Data:     kv.Value,
 		Checksum: checksum,
+		Format:   w.format,
 	}
 }
 
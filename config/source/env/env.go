@@ -0,0 +1,186 @@
+// Package env is a source for environment variables. Vars are
+// lower cased and split on underscores into nested paths e.g.
+// DB_HOST is db.host. Numbers, true and false are parsed as such.
+package env
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-os/config"
+)
+
+type env struct {
+	prefixes []string
+	opts     config.SourceOptions
+}
+
+type watcher struct {
+	exit chan bool
+	once sync.Once
+}
+
+func split(r rune) bool {
+	return r == '_'
+}
+
+// value infers the type of an env var. Only true and false
+// are bools. Numbers with leading zeros e.g. zip codes and
+// ids, NaN and Inf are left as strings.
+func value(s string) interface{} {
+	switch strings.ToLower(s) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if n := strings.TrimLeft(s, "+-"); len(n) > 1 && n[0] == '0' && n[1] != '.' {
+		return s
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f
+	}
+	return s
+}
+
+// trim returns the var without the prefix
+// and false if it doesn't have one of ours
+func (e *env) trim(name string) (string, bool) {
+	if len(e.prefixes) == 0 {
+		return name, true
+	}
+	for _, p := range e.prefixes {
+		if strings.HasPrefix(name, p+"_") {
+			return strings.TrimPrefix(name, p+"_"), true
+		}
+	}
+	return "", false
+}
+
+func (e *env) Read() (*config.ChangeSet, error) {
+	vars := make(map[string]string)
+
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		name, ok := e.trim(parts[0])
+		if !ok || len(name) == 0 {
+			continue
+		}
+
+		vars[strings.ToLower(name)] = parts[1]
+	}
+
+	var keys []string
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	data := make(map[string]interface{})
+
+	for _, k := range keys {
+		// A__B is a.b rather than an empty key
+		path := strings.FieldsFunc(k, split)
+		if len(path) == 0 {
+			continue
+		}
+
+		cur := data
+		for _, p := range path[:len(path)-1] {
+			next, ok := cur[p].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				cur[p] = next
+			}
+			cur = next
+		}
+
+		// DB_HOST wins over DB
+		last := path[len(path)-1]
+		if _, ok := cur[last].(map[string]interface{}); !ok {
+			cur[last] = value(vars[k])
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	h := md5.New()
+	h.Write(b)
+	checksum := fmt.Sprintf("%x", h.Sum(nil))
+
+	return &config.ChangeSet{
+		Source:    e.String(),
+		Timestamp: time.Now(),
+		Data:      b,
+		Checksum:  checksum,
+		Format:    "json",
+	}, nil
+}
+
+// Watch returns a watcher which never fires as the
+// environment of the process doesn't change
+func (e *env) Watch() (config.SourceWatcher, error) {
+	return &watcher{exit: make(chan bool)}, nil
+}
+
+func (e *env) String() string {
+	return "env"
+}
+
+func (w *watcher) Next() (*config.ChangeSet, error) {
+	<-w.exit
+	return nil, errors.New("watcher stopped")
+}
+
+func (w *watcher) Stop() error {
+	w.once.Do(func() {
+		close(w.exit)
+	})
+	return nil
+}
+
+// NewSource loads env vars as config, DB_HOST is db.host.
+// Without a Prefix every var in the environment is loaded
+// e.g. PATH and HOME which may override keys of earlier
+// sources so always set one unless that's what you want.
+func NewSource(opts ...config.SourceOption) config.Source {
+	var options config.SourceOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var prefixes []string
+
+	if options.Context != nil {
+		p, ok := options.Context.Value(prefixKey{}).([]string)
+		if ok {
+			prefixes = p
+		}
+	}
+
+	return &env{
+		prefixes: prefixes,
+		opts:     options,
+	}
+}
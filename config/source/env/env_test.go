@@ -0,0 +1,107 @@
+package env
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-os/config"
+)
+
+func TestEnv(t *testing.T) {
+	vars := map[string]string{
+		"MICRO_DB_HOST":     "localhost",
+		"MICRO_DB_PORT":     "5432",
+		"MICRO_DB_DEBUG":    "true",
+		"MICRO_RATE":        "0.5",
+		"MICRO_ZIP":         "01234",
+		"MICRO_NAN":         "NaN",
+		"MICRO_CACHE__SIZE": "10",
+		"MICRO__":           "empty",
+		"OTHER_DB_HOST":     "other",
+	}
+
+	for k, v := range vars {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	c := config.NewConfig(
+		config.WithSource(NewSource(Prefix("MICRO"))),
+	)
+	defer c.Close()
+
+	if v := c.Get("db", "host").String(""); v != "localhost" {
+		t.Fatalf("Expected localhost got %s", v)
+	}
+
+	if v := c.Get("db", "port").Int(0); v != 5432 {
+		t.Fatalf("Expected 5432 got %d", v)
+	}
+
+	if v := c.Get("db", "debug").Bool(false); !v {
+		t.Fatal("Expected debug to be true")
+	}
+
+	if v := c.Get("rate").Float64(0); v != 0.5 {
+		t.Fatalf("Expected 0.5 got %f", v)
+	}
+
+	if v := c.Get("zip").String(""); v != "01234" {
+		t.Fatalf("Expected 01234 got %s", v)
+	}
+
+	// not a number json can encode
+	if v := c.Get("nan").String(""); v != "NaN" {
+		t.Fatalf("Expected NaN got %s", v)
+	}
+
+	if v := c.Get("other").String(""); len(v) > 0 {
+		t.Fatalf("Expected vars without the prefix to be skipped got %s", v)
+	}
+
+	// empty parts are skipped
+	if v := c.Get("cache", "size").Int(0); v != 10 {
+		t.Fatalf("Expected 10 got %d", v)
+	}
+
+	if b := c.Bytes(); strings.Contains(string(b), `""`) {
+		t.Fatalf("Expected no empty keys got %s", b)
+	}
+}
+
+func TestValue(t *testing.T) {
+	testData := []struct {
+		in  string
+		out interface{}
+	}{
+		{"5432", int64(5432)},
+		{"-12", int64(-12)},
+		{"0", int64(0)},
+		{"0.5", 0.5},
+		{"-0.5", -0.5},
+		{"1e3", 1000.0},
+		{"true", true},
+		{"FALSE", false},
+		// leading zeros are ids
+		{"01234", "01234"},
+		{"-007", "-007"},
+		{"00.5", "00.5"},
+		{"0x1p4", "0x1p4"},
+		// json can't encode them
+		{"NaN", "NaN"},
+		{"Inf", "Inf"},
+		{"-Infinity", "-Infinity"},
+		// only true and false are bools
+		{"T", "T"},
+		{"F", "F"},
+		{"1", int64(1)},
+		{"localhost", "localhost"},
+	}
+
+	for _, d := range testData {
+		if v := value(d.in); v != d.out {
+			t.Fatalf("Expected %v (%T) for %s got %v (%T)", d.out, d.out, d.in, v, v)
+		}
+	}
+}
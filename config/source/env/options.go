@@ -0,0 +1,21 @@
+package env
+
+import (
+	"github.com/micro/go-os/config"
+
+	"golang.org/x/net/context"
+)
+
+type prefixKey struct{}
+
+// Prefix only loads env vars starting with one of the prefixes
+// e.g MICRO. The prefix is stripped so MICRO_DB_HOST is db.host.
+// Set one, without it the whole environment is loaded.
+func Prefix(p ...string) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, prefixKey{}, p)
+	}
+}
@@ -25,6 +25,9 @@ func (e *etcd) Read() (*config.ChangeSet, error) {
 	kv := client.NewKeysAPI(e.client)
 
 	rsp, err := kv.Get(context.Background(), e.opts.Name, nil)
+	if client.IsKeyNotFound(err) {
+		return nil, config.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -38,22 +41,70 @@ func (e *etcd) Read() (*config.ChangeSet, error) {
 		Source:   e.String(),
 		Data:     []byte(rsp.Node.Value),
 		Checksum: checksum,
+		Format:   e.opts.Format,
 	}, nil
 }
 
+// Write sets the data if the checksum of the key matches
+// prev, using the modified index for compare and swap
+func (e *etcd) Write(prev *config.ChangeSet, data []byte) (*config.ChangeSet, error) {
+	kv := client.NewKeysAPI(e.client)
+
+	var opts *client.SetOptions
+
+	if prev != nil {
+		rsp, err := kv.Get(context.Background(), e.opts.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if checksum([]byte(rsp.Node.Value)) != prev.Checksum {
+			return nil, config.ErrConflict
+		}
+
+		opts = &client.SetOptions{PrevIndex: rsp.Node.ModifiedIndex}
+	}
+
+	_, err := kv.Set(context.Background(), e.opts.Name, string(data), opts)
+	if ce, ok := err.(client.Error); ok && ce.Code == client.ErrorCodeTestFailed {
+		return nil, config.ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.ChangeSet{
+		Source:   e.String(),
+		Data:     data,
+		Checksum: checksum(data),
+		Format:   e.opts.Format,
+	}, nil
+}
+
+// Format of the data written to the key
+func (e *etcd) Format() string {
+	return e.opts.Format
+}
+
+func checksum(b []byte) string {
+	h := md5.New()
+	h.Write(b)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
 func (e *etcd) String() string {
 	return "etcd"
 }
 
 func (e *etcd) Watch() (config.SourceWatcher, error) {
-	w, err := newWatcher(e.opts.Name, e.addrs, e.String())
+	w, err := newWatcher(e.opts.Name, e.addrs, e.String(), e.opts.Format)
 	if err != nil {
 		return nil, err
 	}
 	return w, nil
 }
 
-func NewSource(opts ...config.SourceOption) config.Source {
+func NewSource(opts ...config.SourceOption) config.WritableSource {
 	options := config.SourceOptions{
 		Name: DefaultPath,
 	}
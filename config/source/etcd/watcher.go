@@ -12,7 +12,8 @@ import (
 )
 
 type watcher struct {
-	name string
+	name   string
+	format string
 
 	w      client.Watcher
 	ctx    context.Context
@@ -20,7 +21,7 @@ type watcher struct {
 	exit   chan bool
 }
 
-func newWatcher(key string, addrs []string, name string) (config.SourceWatcher, error) {
+func newWatcher(key string, addrs []string, name, format string) (config.SourceWatcher, error) {
 	c, err := client.New(client.Config{
 		Endpoints: addrs,
 	})
@@ -33,6 +34,7 @@ func newWatcher(key string, addrs []string, name string) (config.SourceWatcher,
 
 	return &watcher{
 		name:   name,
+		format: format,
 		ctx:    ctx,
 		cancel: cancel,
 		exit:   make(chan bool),
@@ -59,6 +61,7 @@ func (w *watcher) Next() (*config.ChangeSet, error) {
 			Source:   w.name,
 			Data:     []byte(rsp.Node.Value),
 			Checksum: checksum,
+			Format:   w.format,
 		}, nil
 	}
 }
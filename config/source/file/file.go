@@ -2,9 +2,14 @@ package file
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/micro/go-os/config"
 )
@@ -12,14 +17,83 @@ import (
 // Currently a single file reader
 type file struct {
 	opts config.SourceOptions
+	// merges files in directory mode
+	reader config.Reader
+
+	// serialises writes
+	sync.Mutex
 }
 
 var (
 	DefaultFileName = "config.values"
+
+	// Formats by file extension
+	Formats = map[string]string{
+		".json":       "json",
+		".yaml":       "yaml",
+		".yml":        "yaml",
+		".toml":       "toml",
+		".hcl":        "hcl",
+		".properties": "properties",
+	}
 )
 
-func (f *file) Read() (*config.ChangeSet, error) {
-	fh, err := os.Open(f.opts.Name)
+// format returns the format set in options or
+// otherwise the one matching the file extension
+func (f *file) format(name string) string {
+	if len(f.opts.Format) > 0 {
+		return f.opts.Format
+	}
+	return Formats[strings.ToLower(filepath.Ext(name))]
+}
+
+// glob returns the pattern of the files to load
+// or false if the source is a single file
+func (f *file) glob() (string, bool) {
+	if strings.ContainsAny(f.opts.Name, "*?[") {
+		return f.opts.Name, true
+	}
+	if fi, err := os.Stat(f.opts.Name); err == nil && fi.IsDir() {
+		return filepath.Join(f.opts.Name, "*"), true
+	}
+	return "", false
+}
+
+// dirs are the directories watched for changes. A glob may
+// match files in many e.g. conf/*/app.json, only those which
+// exist when the watch starts are watched.
+func (f *file) dirs() ([]string, error) {
+	pattern, ok := f.glob()
+	if !ok {
+		return []string{filepath.Dir(f.opts.Name)}, nil
+	}
+
+	dir := filepath.Dir(pattern)
+	if !strings.ContainsAny(dir, "*?[") {
+		return []string{dir}, nil
+	}
+
+	names, err := filepath.Glob(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, name := range names {
+		if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+			dirs = append(dirs, name)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no directories match %s", dir)
+	}
+
+	return dirs, nil
+}
+
+func (f *file) readFile(name string) (*config.ChangeSet, error) {
+	fh, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
@@ -43,26 +117,162 @@ func (f *file) Read() (*config.ChangeSet, error) {
 		Timestamp: info.ModTime(),
 		Data:      b,
 		Checksum:  checksum,
+		Format:    f.format(name),
 	}, nil
 }
 
+// readFiles merges the files matching the pattern in lexical
+// order. Hidden files, directories and files in unknown formats
+// are skipped e.g. the ..data link of a kubernetes config map.
+func (f *file) readFiles(pattern string) (*config.ChangeSet, error) {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []*config.ChangeSet
+	var timestamp time.Time
+
+	for _, name := range names {
+		if strings.HasPrefix(filepath.Base(name), ".") || len(f.format(name)) == 0 {
+			continue
+		}
+
+		// follows symlinks
+		if fi, err := os.Stat(name); err != nil || fi.IsDir() {
+			continue
+		}
+
+		ch, err := f.readFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if ch.Timestamp.After(timestamp) {
+			timestamp = ch.Timestamp
+		}
+
+		sets = append(sets, ch)
+	}
+
+	ch, err := f.reader.Parse(sets...)
+	if err != nil {
+		return nil, err
+	}
+
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	ch.Source = f.String()
+	ch.Timestamp = timestamp
+	return ch, nil
+}
+
+func (f *file) Read() (*config.ChangeSet, error) {
+	if pattern, ok := f.glob(); ok {
+		return f.readFiles(pattern)
+	}
+	return f.readFile(f.opts.Name)
+}
+
+// Write replaces the file if its checksum matches prev. The new
+// file is renamed over the old one so readers never see part
+// of it. Only writes from this process are serialised.
+func (f *file) Write(prev *config.ChangeSet, data []byte) (*config.ChangeSet, error) {
+	if _, ok := f.glob(); ok {
+		return nil, errors.New("can't write to a directory")
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	mode := os.FileMode(0644)
+
+	cur, err := f.readFile(f.opts.Name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if prev != nil && (cur == nil || cur.Checksum != prev.Checksum) {
+		return nil, config.ErrConflict
+	}
+
+	if fi, err := os.Stat(f.opts.Name); err == nil {
+		mode = fi.Mode()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.opts.Name), "."+filepath.Base(f.opts.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), f.opts.Name); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return f.readFile(f.opts.Name)
+}
+
+// Format of the data written to the file
+func (f *file) Format() string {
+	return f.format(f.opts.Name)
+}
+
 func (f *file) String() string {
 	return "file"
 }
 
 func (f *file) Watch() (config.SourceWatcher, error) {
-	if _, err := os.Stat(f.opts.Name); err != nil {
-		return nil, err
+	if _, ok := f.glob(); !ok {
+		if _, err := os.Stat(f.opts.Name); err != nil {
+			return nil, err
+		}
 	}
 	return newWatcher(f)
 }
 
-func NewSource(opts ...config.SourceOption) config.Source {
+// NewSource creates a source for the file set by SourceName. If
+// the name is a directory or glob e.g. conf.d/*.json every file
+// matching is loaded in lexical order and merged. Globs with
+// wildcard directories e.g. conf/*/app.json only watch the
+// directories which exist when watching starts.
+func NewSource(opts ...config.SourceOption) config.WritableSource {
 	options := config.SourceOptions{
 		Name: DefaultFileName,
 	}
 	for _, o := range opts {
 		o(&options)
 	}
-	return &file{opts: options}
+
+	// references are resolved once all sources are merged
+	f := &file{
+		opts:   options,
+		reader: config.NewReader(config.ReaderInterpolate(false)),
+	}
+
+	if options.Context != nil {
+		if r, ok := options.Context.Value(readerKey{}).(config.Reader); ok {
+			f.reader = r
+		}
+	}
+
+	return f
 }
@@ -2,8 +2,10 @@ package file
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,3 +39,260 @@ func TestFile(t *testing.T) {
 		t.Error("data from file does not match")
 	}
 }
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, name, data string) {
+	if err := ioutil.WriteFile(name, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// next waits for the next change set from the watcher
+func next(t *testing.T, w config.SourceWatcher) *config.ChangeSet {
+	ch := make(chan *config.ChangeSet, 1)
+	go func() {
+		c, err := w.Next()
+		if err != nil {
+			return
+		}
+		ch <- c
+	}()
+
+	select {
+	case c := <-ch:
+		return c
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for change")
+	}
+	return nil
+}
+
+func TestDir(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "10-base.json"), `{"db": {"host": "localhost", "port": 5432}}`)
+	writeFile(t, filepath.Join(dir, "20-prod.yaml"), "db:\n  host: prod\n")
+	writeFile(t, filepath.Join(dir, "README.md"), "not config")
+
+	for _, name := range []string{dir, filepath.Join(dir, "*")} {
+		c := config.NewConfig(config.WithSource(NewSource(config.SourceName(name))))
+
+		if v := c.Get("db", "host").String(""); v != "prod" {
+			t.Fatalf("Expected prod got %s", v)
+		}
+
+		if v := c.Get("db", "port").Int(0); v != 5432 {
+			t.Fatalf("Expected 5432 got %d", v)
+		}
+
+		c.Close()
+	}
+
+	// only json files
+	c, err := NewSource(config.SourceName(filepath.Join(dir, "*.json"))).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(c.Data), "localhost") {
+		t.Fatalf("Expected only the json file got %s", c.Data)
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "10-base.json"), `{"foo": "bar"}`)
+
+	w, err := NewSource(config.SourceName(dir)).Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// additions
+	writeFile(t, filepath.Join(dir, "20-override.json"), `{"foo": "baz"}`)
+
+	if c := next(t, w); !strings.Contains(string(c.Data), "baz") {
+		t.Fatalf("Expected baz got %s", c.Data)
+	}
+
+	// and removals
+	os.Remove(filepath.Join(dir, "20-override.json"))
+
+	if c := next(t, w); !strings.Contains(string(c.Data), "bar") {
+		t.Fatalf("Expected bar got %s", c.Data)
+	}
+}
+
+func TestWatchGlobDirs(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"a", "b"} {
+		os.Mkdir(filepath.Join(dir, d), 0700)
+		writeFile(t, filepath.Join(dir, d, "app.json"), fmt.Sprintf(`{"%s": "1"}`, d))
+	}
+
+	w, err := NewSource(config.SourceName(filepath.Join(dir, "*", "app.json"))).Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// changes in every matching directory
+	for _, d := range []string{"a", "b"} {
+		writeFile(t, filepath.Join(dir, d, "app.json"), fmt.Sprintf(`{"%s": "2"}`, d))
+
+		if c := next(t, w); !strings.Contains(string(c.Data), fmt.Sprintf(`"%s":"2"`, d)) {
+			t.Fatalf("Expected %s changed got %s", d, c.Data)
+		}
+	}
+
+	// nothing to watch
+	if _, err := NewSource(config.SourceName(filepath.Join(dir, "c*", "app.json"))).Watch(); err == nil {
+		t.Fatal("Expected error watching no directories")
+	}
+}
+
+func TestWatchRename(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	writeFile(t, path, `{"foo": "bar"}`)
+
+	w, err := NewSource(config.SourceName(path)).Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	for _, v := range []string{"baz", "qux"} {
+		// written by an editor
+		tmp := filepath.Join(dir, ".config.json.swp")
+		writeFile(t, tmp, fmt.Sprintf(`{"foo": "%s"}`, v))
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+
+		if c := next(t, w); !strings.Contains(string(c.Data), v) {
+			t.Fatalf("Expected %s got %s", v, c.Data)
+		}
+	}
+}
+
+func TestWatchConfigMap(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// the layout of a kubernetes config map volume
+	version := func(v string) {
+		os.Mkdir(filepath.Join(dir, "..v"+v), 0700)
+		writeFile(t, filepath.Join(dir, "..v"+v, "config.json"), fmt.Sprintf(`{"foo": "%s"}`, v))
+		if err := os.Symlink("..v"+v, filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	version("1")
+
+	if err := os.Symlink(filepath.Join("..data", "config.json"), filepath.Join(dir, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	src := NewSource(config.SourceName(dir))
+
+	c, err := src.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(c.Data), `"1"`) {
+		t.Fatalf("Expected 1 got %s", c.Data)
+	}
+
+	w, err := src.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	version("2")
+
+	if c := next(t, w); !strings.Contains(string(c.Data), `"2"`) {
+		t.Fatalf("Expected 2 got %s", c.Data)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "foo: bar\n")
+
+	src := NewSource(config.SourceName(path))
+
+	prev, err := src.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := src.Write(prev, []byte("foo: baz\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ch.Format != "yaml" || string(ch.Data) != "foo: baz\n" {
+		t.Fatalf("Unexpected change set %+v", ch)
+	}
+
+	// based on a stale read
+	if _, err := src.Write(prev, []byte("foo: qux\n")); err != config.ErrConflict {
+		t.Fatalf("Expected conflict got %v", err)
+	}
+
+	if _, err := NewSource(config.SourceName(dir)).Write(nil, nil); err == nil {
+		t.Fatal("Expected error writing to a directory")
+	}
+}
+
+func TestWriteNew(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	src := NewSource(config.SourceName(path))
+
+	// nothing to read yet
+	_, err := config.WriteSource(src, func(v config.Values) error {
+		v.Set(5432, "db", "port")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// in the format of the file
+	if s := string(b); s != "db:\n  port: 5432\n" {
+		t.Fatalf("Unexpected data written %q", s)
+	}
+}
@@ -0,0 +1,21 @@
+package file
+
+import (
+	"github.com/micro/go-os/config"
+
+	"golang.org/x/net/context"
+)
+
+type readerKey struct{}
+
+// Reader merges the files in directory mode. Set it if the
+// config reader has encoders for other formats. Interpolation
+// should be off so references to other sources resolve.
+func Reader(r config.Reader) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, readerKey{}, r)
+	}
+}
@@ -2,56 +2,100 @@ package file
 
 import (
 	"errors"
+	"os"
+	"sync"
 
 	"github.com/micro/go-os/config"
 	"gopkg.in/fsnotify.v1"
 )
 
+// watcher watches the directory rather than the file so
+// editors which write a new file and rename it over the old
+// one and kubernetes config map symlink swaps are seen.
 type watcher struct {
 	f *file
 
 	fw   *fsnotify.Watcher
 	exit chan bool
+	once sync.Once
+
+	// checksum of the last change set
+	checksum string
 }
 
 func newWatcher(f *file) (config.SourceWatcher, error) {
+	dirs, err := f.dirs()
+	if err != nil {
+		return nil, err
+	}
+
 	fw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
-	fw.Add(f.opts.Name)
+	for _, dir := range dirs {
+		if err := fw.Add(dir); err != nil {
+			fw.Close()
+			return nil, err
+		}
+	}
 
-	return &watcher{
+	w := &watcher{
 		f:    f,
 		fw:   fw,
 		exit: make(chan bool),
-	}, nil
+	}
+
+	if c, err := f.Read(); err == nil {
+		w.checksum = c.Checksum
+	}
+
+	return w, nil
 }
 
 func (w *watcher) Next() (*config.ChangeSet, error) {
-	// is it closed?
-	select {
-	case <-w.exit:
-		return nil, errors.New("watcher stopped")
-	default:
-	}
+	for {
+		// is it closed?
+		select {
+		case <-w.exit:
+			return nil, errors.New("watcher stopped")
+		default:
+		}
 
-	// try get the event
-	select {
-	case <-w.fw.Events:
-		c, err := w.f.Read()
-		if err != nil {
+		// try get the event
+		select {
+		case _, ok := <-w.fw.Events:
+			if !ok {
+				return nil, errors.New("watcher stopped")
+			}
+			c, err := w.f.Read()
+			// the file is briefly missing while it's replaced
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			// other files in the directory changed
+			if c.Checksum == w.checksum {
+				continue
+			}
+			w.checksum = c.Checksum
+			return c, nil
+		case err := <-w.fw.Errors:
 			return nil, err
+		case <-w.exit:
+			return nil, errors.New("watcher stopped")
 		}
-		return c, nil
-	case err := <-w.fw.Errors:
-		return nil, err
-	case <-w.exit:
-		return nil, errors.New("watcher stopped")
 	}
 }
 
 func (w *watcher) Stop() error {
-	return w.fw.Close()
+	var err error
+	w.once.Do(func() {
+		close(w.exit)
+		err = w.fw.Close()
+	})
+	return err
 }
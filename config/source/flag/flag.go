@@ -0,0 +1,144 @@
+// Package flag is a source for command line flags. Flag names
+// are split on dots and dashes into nested paths so -db-host
+// or -db.host is db.host.
+package flag
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-os/config"
+)
+
+type flagSource struct {
+	fs    *flag.FlagSet
+	unset bool
+	opts  config.SourceOptions
+}
+
+type watcher struct {
+	exit chan bool
+	once sync.Once
+}
+
+func split(r rune) bool {
+	return r == '.' || r == '-'
+}
+
+func (f *flagSource) Read() (*config.ChangeSet, error) {
+	var flags []*flag.Flag
+
+	// visited in lexical order
+	visit := func(fl *flag.Flag) {
+		flags = append(flags, fl)
+	}
+
+	if f.unset {
+		f.fs.VisitAll(visit)
+	} else {
+		f.fs.Visit(visit)
+	}
+
+	data := make(map[string]interface{})
+
+	for _, fl := range flags {
+		path := strings.FieldsFunc(strings.ToLower(fl.Name), split)
+		if len(path) == 0 {
+			continue
+		}
+
+		cur := data
+		for _, p := range path[:len(path)-1] {
+			next, ok := cur[p].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				cur[p] = next
+			}
+			cur = next
+		}
+
+		// keep the type of the flag if we can but durations
+		// are strings so Value.Duration can parse them
+		var val interface{} = fl.Value.String()
+		if g, ok := fl.Value.(flag.Getter); ok {
+			if _, ok := g.Get().(time.Duration); !ok {
+				val = g.Get()
+			}
+		}
+
+		last := path[len(path)-1]
+		if _, ok := cur[last].(map[string]interface{}); !ok {
+			cur[last] = val
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	h := md5.New()
+	h.Write(b)
+	checksum := fmt.Sprintf("%x", h.Sum(nil))
+
+	return &config.ChangeSet{
+		Source:    f.String(),
+		Timestamp: time.Now(),
+		Data:      b,
+		Checksum:  checksum,
+		Format:    "json",
+	}, nil
+}
+
+// Watch returns a watcher which never fires as
+// flags don't change once they're parsed
+func (f *flagSource) Watch() (config.SourceWatcher, error) {
+	return &watcher{exit: make(chan bool)}, nil
+}
+
+func (f *flagSource) String() string {
+	return "flag"
+}
+
+func (w *watcher) Next() (*config.ChangeSet, error) {
+	<-w.exit
+	return nil, errors.New("watcher stopped")
+}
+
+func (w *watcher) Stop() error {
+	w.once.Do(func() {
+		close(w.exit)
+	})
+	return nil
+}
+
+func NewSource(opts ...config.SourceOption) config.Source {
+	var options config.SourceOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	fs := flag.CommandLine
+	var unset bool
+
+	if options.Context != nil {
+		if f, ok := options.Context.Value(flagSetKey{}).(*flag.FlagSet); ok {
+			fs = f
+		}
+		if u, ok := options.Context.Value(includeUnsetKey{}).(bool); ok {
+			unset = u
+		}
+	}
+
+	return &flagSource{
+		fs:    fs,
+		unset: unset,
+		opts:  options,
+	}
+}
@@ -0,0 +1,61 @@
+package flag
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/micro/go-os/config"
+	"github.com/micro/go-os/config/source/memory"
+)
+
+func TestFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("db-host", "localhost", "")
+	fs.Int("db.port", 5432, "")
+	fs.Duration("timeout", time.Second, "")
+	fs.Bool("debug", false, "")
+
+	if err := fs.Parse([]string{"-db-host", "flag.host", "-debug", "-timeout", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// flags override the file
+	c := config.NewConfig(
+		config.WithSource(memory.NewSource(memory.Data([]byte(`{"db": {"port": 3306}}`)))),
+		config.WithSource(NewSource(FlagSet(fs))),
+	)
+	defer c.Close()
+
+	if v := c.Get("db", "host").String(""); v != "flag.host" {
+		t.Fatalf("Expected flag.host got %s", v)
+	}
+
+	if v := c.Get("debug").Bool(false); !v {
+		t.Fatal("Expected debug to be true")
+	}
+
+	if v := c.Get("timeout").Duration(0); v != time.Minute {
+		t.Fatalf("Expected 1m got %v", v)
+	}
+
+	// unset defaults don't override other sources
+	if v := c.Get("db", "port").Int(0); v != 3306 {
+		t.Fatalf("Expected 3306 got %d", v)
+	}
+
+	vals, err := NewSource(FlagSet(fs), IncludeUnset(true)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := config.NewReader()
+	v, err := r.Values(vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := v.Get("db", "port").Int(0); p != 5432 {
+		t.Fatalf("Expected 5432 got %d", p)
+	}
+}
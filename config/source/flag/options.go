@@ -0,0 +1,35 @@
+package flag
+
+import (
+	"flag"
+
+	"github.com/micro/go-os/config"
+
+	"golang.org/x/net/context"
+)
+
+type flagSetKey struct{}
+
+type includeUnsetKey struct{}
+
+// FlagSet to read flags from. Defaults to flag.CommandLine.
+func FlagSet(fs *flag.FlagSet) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, flagSetKey{}, fs)
+	}
+}
+
+// IncludeUnset loads the defaults of flags which weren't set.
+// By default only flags set on the command line are loaded
+// so they don't override other sources with their defaults.
+func IncludeUnset(b bool) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, includeUnsetKey{}, b)
+	}
+}
@@ -14,6 +14,8 @@ type Source struct {
 	sync.RWMutex
 	ChangeSet *config.ChangeSet
 	Watchers  map[string]*Watcher
+	// Format of the data
+	Format string
 }
 
 func (s *Source) Read() (*config.ChangeSet, error) {
@@ -23,6 +25,7 @@ func (s *Source) Read() (*config.ChangeSet, error) {
 		Data:      s.ChangeSet.Data,
 		Checksum:  s.ChangeSet.Checksum,
 		Source:    s.ChangeSet.Source,
+		Format:    s.ChangeSet.Format,
 	}
 	s.RUnlock()
 	return cs, nil
@@ -30,9 +33,10 @@ func (s *Source) Read() (*config.ChangeSet, error) {
 
 func (s *Source) Watch() (config.SourceWatcher, error) {
 	w := &Watcher{
-		Id:      uuid.NewUUID().String(),
+		Id:      uuid.NewRandom().String(),
 		Updates: make(chan *config.ChangeSet, 100),
 		Source:  s,
+		exit:    make(chan bool),
 	}
 
 	s.Lock()
@@ -43,18 +47,38 @@ func (s *Source) Watch() (config.SourceWatcher, error) {
 
 // Update allows manual updates of the config data.
 func (s *Source) Update(data []byte) {
+	s.Lock()
+	s.update(data)
+	s.Unlock()
+}
+
+// Write updates the data if the checksum matches prev
+func (s *Source) Write(prev *config.ChangeSet, data []byte) (*config.ChangeSet, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if prev != nil && s.ChangeSet.Checksum != prev.Checksum {
+		return nil, config.ErrConflict
+	}
+
+	s.update(data)
+	return s.ChangeSet, nil
+}
+
+// update must be called with the lock held
+func (s *Source) update(data []byte) {
 	// hash the file
 	h := md5.New()
 	h.Write(data)
 	checksum := fmt.Sprintf("%x", h.Sum(nil))
 
-	s.Lock()
 	// update changeset
 	s.ChangeSet = &config.ChangeSet{
 		Timestamp: time.Now(),
 		Data:      data,
 		Checksum:  checksum,
 		Source:    "memory",
+		Format:    s.Format,
 	}
 
 	// update watchers
@@ -64,7 +88,6 @@ func (s *Source) Update(data []byte) {
 		default:
 		}
 	}
-	s.Unlock()
 }
 
 func (s *Source) String() string {
@@ -88,6 +111,7 @@ func NewSource(opts ...config.SourceOption) *Source {
 
 	s := &Source{
 		Watchers: make(map[string]*Watcher),
+		Format:   options.Format,
 	}
 	s.Update(data)
 	return s
@@ -1,6 +1,9 @@
 package memory
 
 import (
+	"errors"
+	"sync"
+
 	"github.com/micro/go-os/config"
 )
 
@@ -8,16 +11,27 @@ type Watcher struct {
 	Id      string
 	Updates chan *config.ChangeSet
 	Source  *Source
+
+	exit chan bool
+	once sync.Once
 }
 
 func (w *Watcher) Next() (*config.ChangeSet, error) {
-	cs := <-w.Updates
-	return cs, nil
+	select {
+	case cs := <-w.Updates:
+		return cs, nil
+	case <-w.exit:
+		return nil, errors.New("watcher stopped")
+	}
 }
 
 func (w *Watcher) Stop() error {
 	w.Source.Lock()
 	delete(w.Source.Watchers, w.Id)
 	w.Source.Unlock()
+
+	w.once.Do(func() {
+		close(w.exit)
+	})
 	return nil
 }
@@ -0,0 +1,69 @@
+package url
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/micro/go-os/config"
+
+	"golang.org/x/net/context"
+)
+
+type headerKey struct{}
+type clientKey struct{}
+type intervalKey struct{}
+type longPollKey struct{}
+
+func setOption(o *config.SourceOptions, k, v interface{}) {
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	o.Context = context.WithValue(o.Context, k, v)
+}
+
+// Header is set on every request e.g. Authorization
+func Header(k, v string) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		h := http.Header{}
+		if o.Context != nil {
+			if hh, ok := o.Context.Value(headerKey{}).(http.Header); ok {
+				for k, v := range hh {
+					h[k] = v
+				}
+			}
+		}
+		h.Set(k, v)
+		setOption(o, headerKey{}, h)
+	}
+}
+
+// BearerToken sets the Authorization header
+func BearerToken(t string) config.SourceOption {
+	return Header("Authorization", "Bearer "+t)
+}
+
+// Client is the http client used for requests. Its timeout
+// must be longer than the LongPoll wait.
+func Client(c *http.Client) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		setOption(o, clientKey{}, c)
+	}
+}
+
+// Interval is how often the watcher refreshes
+func Interval(d time.Duration) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		setOption(o, intervalKey{}, d)
+	}
+}
+
+// LongPoll makes the watcher hold requests open for up to wait
+// rather than refreshing at an interval. The server is asked to
+// wait with the Prefer header and should reply 304 Not Modified
+// if nothing changed. Servers which reply sooner are polled no
+// more than every wait.
+func LongPoll(wait time.Duration) config.SourceOption {
+	return func(o *config.SourceOptions) {
+		setOption(o, longPollKey{}, wait)
+	}
+}
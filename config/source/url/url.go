@@ -0,0 +1,186 @@
+// Package url is a config source which fetches a document over http(s)
+package url
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/micro/go-os/config"
+
+	"golang.org/x/net/context"
+)
+
+type urlSource struct {
+	opts     config.SourceOptions
+	client   *http.Client
+	header   http.Header
+	interval time.Duration
+	wait     time.Duration
+
+	sync.Mutex
+	etag string
+	last *config.ChangeSet
+}
+
+var (
+	DefaultURL      = "http://localhost:8080/config"
+	DefaultInterval = time.Second * 30
+	// DefaultTimeout limits requests by the default client.
+	// Long polls are allowed the wait on top.
+	DefaultTimeout = time.Second * 30
+
+	// Formats by content type
+	Formats = map[string]string{
+		"application/json":       "json",
+		"application/yaml":       "yaml",
+		"application/x-yaml":     "yaml",
+		"text/yaml":              "yaml",
+		"application/toml":       "toml",
+		"application/hcl":        "hcl",
+		"text/x-java-properties": "properties",
+	}
+)
+
+// format returns the format set in options or
+// otherwise the one matching the content type
+func (u *urlSource) format(rsp *http.Response) string {
+	if len(u.opts.Format) > 0 {
+		return u.opts.Format
+	}
+	t, _, err := mime.ParseMediaType(rsp.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return Formats[t]
+}
+
+// fetch gets the document. If it's not modified since the
+// last fetch the previous change set is returned and changed
+// is false. Wait asks the server to hold the request.
+func (u *urlSource) fetch(ctx context.Context, wait time.Duration) (*config.ChangeSet, bool, error) {
+	req, err := http.NewRequest("GET", u.opts.Name, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range u.header {
+		req.Header[k] = v
+	}
+
+	u.Lock()
+	etag := u.etag
+	last := u.last
+	u.Unlock()
+
+	if len(etag) > 0 && last != nil {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	if wait > 0 {
+		req.Header.Set("Prefer", fmt.Sprintf("wait=%d", int(wait.Seconds())))
+	}
+
+	rsp, err := u.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode == http.StatusNotModified && last != nil {
+		return last, false, nil
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("%s returned %s", u.opts.Name, rsp.Status)
+	}
+
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// hash the document
+	h := md5.New()
+	h.Write(b)
+	checksum := fmt.Sprintf("%x", h.Sum(nil))
+
+	timestamp, err := http.ParseTime(rsp.Header.Get("Last-Modified"))
+	if err != nil {
+		timestamp = time.Now()
+	}
+
+	ch := &config.ChangeSet{
+		Source:    u.String(),
+		Timestamp: timestamp,
+		Data:      b,
+		Checksum:  checksum,
+		Format:    u.format(rsp),
+	}
+
+	u.Lock()
+	u.etag = rsp.Header.Get("ETag")
+	u.last = ch
+	u.Unlock()
+
+	return ch, last == nil || last.Checksum != checksum, nil
+}
+
+func (u *urlSource) Read() (*config.ChangeSet, error) {
+	ch, _, err := u.fetch(context.Background(), 0)
+	return ch, err
+}
+
+func (u *urlSource) String() string {
+	return "url"
+}
+
+func (u *urlSource) Watch() (config.SourceWatcher, error) {
+	return newWatcher(u), nil
+}
+
+// NewSource creates a source which fetches the url set by
+// SourceName. The watcher refreshes at an interval or long
+// polls. ETags are used so unchanged documents aren't sent.
+func NewSource(opts ...config.SourceOption) config.Source {
+	options := config.SourceOptions{
+		Name: DefaultURL,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	u := &urlSource{
+		opts:     options,
+		interval: DefaultInterval,
+	}
+
+	if c := options.Context; c != nil {
+		if h, ok := c.Value(headerKey{}).(http.Header); ok {
+			u.header = h
+		}
+		if cl, ok := c.Value(clientKey{}).(*http.Client); ok {
+			u.client = cl
+		}
+		if d, ok := c.Value(intervalKey{}).(time.Duration); ok && d > 0 {
+			u.interval = d
+		}
+		if d, ok := c.Value(longPollKey{}).(time.Duration); ok {
+			u.wait = d
+		}
+	}
+
+	if u.client == nil {
+		u.client = &http.Client{
+			Timeout: DefaultTimeout + u.wait,
+		}
+	}
+
+	return u
+}
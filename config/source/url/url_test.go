@@ -0,0 +1,201 @@
+package url
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-os/config"
+)
+
+type testServer struct {
+	sync.Mutex
+	data    string
+	version int
+	// full responses sent
+	sent int
+	// closed on update to wake long polls
+	changed chan bool
+}
+
+func newTestServer(data string) *testServer {
+	return &testServer{data: data, version: 1, changed: make(chan bool)}
+}
+
+func (s *testServer) update(data string) {
+	s.Lock()
+	s.data = data
+	s.version++
+	close(s.changed)
+	s.changed = make(chan bool)
+	s.Unlock()
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.Lock()
+	etag := fmt.Sprintf(`"%d"`, s.version)
+	changed := s.changed
+	s.Unlock()
+
+	if r.Header.Get("If-None-Match") == etag {
+		// hold long polls until there's a change
+		if len(r.Header.Get("Prefer")) == 0 {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.sent++
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, s.version))
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write([]byte(s.data))
+}
+
+func TestRead(t *testing.T) {
+	s := newTestServer("foo: bar")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	src := NewSource(config.SourceName(ts.URL), BearerToken("token"))
+
+	for i := 0; i < 3; i++ {
+		ch, err := src.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ch.Format != "yaml" || string(ch.Data) != "foo: bar" {
+			t.Fatalf("Unexpected change set %+v", ch)
+		}
+	}
+
+	// unchanged documents aren't sent again
+	s.Lock()
+	sent := s.sent
+	s.Unlock()
+
+	if sent != 1 {
+		t.Fatalf("Expected 1 full response got %d", sent)
+	}
+
+	if _, err := NewSource(config.SourceName(ts.URL)).Read(); err == nil {
+		t.Fatal("Expected error without auth")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	testData := []struct {
+		name string
+		opt  config.SourceOption
+	}{
+		{"interval", Interval(time.Millisecond * 10)},
+		{"long poll", LongPoll(time.Second)},
+	}
+
+	for _, d := range testData {
+		s := newTestServer("foo: bar")
+		ts := httptest.NewServer(s)
+
+		src := NewSource(config.SourceName(ts.URL), BearerToken("token"), d.opt)
+
+		if _, err := src.Read(); err != nil {
+			t.Fatal(err)
+		}
+
+		w, err := src.Watch()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			s.update("foo: baz")
+		}()
+
+		ch, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(ch.Data) != "foo: baz" {
+			t.Fatalf("%s expected update got %s", d.name, ch.Data)
+		}
+
+		w.Stop()
+		ts.Close()
+	}
+}
+
+func TestLongPollUnsupported(t *testing.T) {
+	var mtx sync.Mutex
+	var requests int
+
+	// ignores the wait and replies at once
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		requests++
+		mtx.Unlock()
+
+		if len(r.Header.Get("If-None-Match")) > 0 {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		w.Write([]byte("foo: bar"))
+	}))
+	defer ts.Close()
+
+	src := NewSource(config.SourceName(ts.URL), LongPoll(time.Second))
+
+	if _, err := src.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := src.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go w.Next()
+
+	time.Sleep(time.Millisecond * 200)
+	w.Stop()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	// the read and one poll
+	if requests != 2 {
+		t.Fatalf("Expected 2 requests got %d", requests)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	u := NewSource(Interval(0), LongPoll(time.Minute)).(*urlSource)
+
+	if u.interval != DefaultInterval {
+		t.Fatalf("Expected interval %v got %v", DefaultInterval, u.interval)
+	}
+
+	// long polls mustn't time out
+	if u.client.Timeout <= time.Minute {
+		t.Fatalf("Expected timeout over a minute got %v", u.client.Timeout)
+	}
+}
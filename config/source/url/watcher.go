@@ -0,0 +1,83 @@
+package url
+
+import (
+	"errors"
+	"time"
+
+	"github.com/micro/go-os/config"
+
+	"golang.org/x/net/context"
+)
+
+type watcher struct {
+	u *urlSource
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// earliest time for the next long poll
+	next time.Time
+}
+
+var (
+	// minPoll is the least time between long polls
+	// which return changes
+	minPoll = time.Second
+)
+
+func newWatcher(u *urlSource) config.SourceWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		u:      u,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (w *watcher) Next() (*config.ChangeSet, error) {
+	for {
+		wait := w.u.interval
+
+		// long polls go straight back to the server unless
+		// the last came back too soon e.g. the server doesn't
+		// support them and replies at once
+		if w.u.wait > 0 {
+			wait = w.next.Sub(time.Now())
+		}
+
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-w.ctx.Done():
+				return nil, errors.New("watcher stopped")
+			}
+		}
+
+		start := time.Now()
+
+		ch, changed, err := w.u.fetch(w.ctx, w.u.wait)
+
+		select {
+		case <-w.ctx.Done():
+			return nil, errors.New("watcher stopped")
+		default:
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if changed {
+			w.next = start.Add(minPoll)
+			return ch, nil
+		}
+
+		// nothing changed so poll no more than every wait
+		w.next = start.Add(w.u.wait)
+	}
+}
+
+func (w *watcher) Stop() error {
+	w.cancel()
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	proto "github.com/micro/config-srv/proto/config"
+	"github.com/micro/go-micro/client"
+	"golang.org/x/net/context"
+)
+
+type testConfigClient struct {
+	proto.ConfigClient
+	reqs []*proto.ReadRequest
+}
+
+func (c *testConfigClient) Read(ctx context.Context, req *proto.ReadRequest, opts ...client.CallOption) (*proto.ReadResponse, error) {
+	c.reqs = append(c.reqs, req)
+	return &proto.ReadResponse{
+		Change: &proto.Change{
+			Id:   req.Id,
+			Path: req.Path,
+			ChangeSet: &proto.ChangeSet{
+				Data: `{"foo": "bar"}`,
+			},
+		},
+	}, nil
+}
+
+func TestSourceNamespace(t *testing.T) {
+	c := &testConfigClient{}
+
+	s := &source{
+		opts: SourceOptions{
+			Name:      DefaultSourceName,
+			Namespace: "go.micro.srv.foo",
+		},
+		client: c,
+	}
+
+	if _, err := s.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.reqs) != 1 || c.reqs[0].Id != DefaultSourceName || c.reqs[0].Path != "go.micro.srv.foo" {
+		t.Fatalf("Expected the namespace to be read got %+v", c.reqs)
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is a struct field and its config tags e.g.
+//
+//	Port    int           `config:"port,required" min:"1" max:"65535"`
+//	Timeout time.Duration `config:"timeout" default:"5s"`
+//
+// Fields without a config tag use the lower cased field name.
+// A tag of "-" skips the field.
+type field struct {
+	// index of the field in the struct
+	index []int
+	path  []string
+	typ   reflect.Type
+
+	required bool
+	def      string
+	min      string
+	max      string
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fields returns the fields of the struct type t
+// including those of nested structs, flattened
+func fields(t reflect.Type, path []string, index []int) []*field {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var fs []*field
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		// unexported
+		if len(sf.PkgPath) > 0 {
+			continue
+		}
+
+		tag := sf.Tag.Get("config")
+		if tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+
+		name := parts[0]
+		if len(name) == 0 {
+			name = strings.ToLower(sf.Name)
+		}
+
+		f := &field{
+			index: append(append([]int{}, index...), i),
+			path:  append(append([]string{}, path...), name),
+			typ:   sf.Type,
+			def:   sf.Tag.Get("default"),
+			min:   sf.Tag.Get("min"),
+			max:   sf.Tag.Get("max"),
+		}
+
+		for _, opt := range parts[1:] {
+			if opt == "required" {
+				f.required = true
+			}
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			fs = append(fs, fields(sf.Type, f.path, f.index)...)
+			continue
+		}
+
+		fs = append(fs, f)
+	}
+
+	return fs
+}
+
+// parse converts a tag value e.g. a default to the field type
+func (f *field) parse(s string) (interface{}, error) {
+	if f.typ == durationType {
+		if _, err := time.ParseDuration(s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	switch f.typ.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	case reflect.Slice:
+		var vals []interface{}
+		for _, v := range strings.Split(s, ",") {
+			vals = append(vals, strings.TrimSpace(v))
+		}
+		return vals, nil
+	}
+
+	return s, nil
+}
@@ -7,7 +7,8 @@ import (
 )
 
 type sourceWatcher struct {
-	w proto.Config_WatchClient
+	w      proto.Config_WatchClient
+	format string
 }
 
 func (w *sourceWatcher) Next() (*ChangeSet, error) {
@@ -20,6 +21,7 @@ func (w *sourceWatcher) Next() (*ChangeSet, error) {
 		Data:      []byte(c.ChangeSet.Data),
 		Checksum:  c.ChangeSet.Checksum,
 		Source:    c.ChangeSet.Source,
+		Format:    w.format,
 	}, nil
 }
 
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// formatter is implemented by writable sources which know
+// the format of their data before any is written
+type formatter interface {
+	Format() string
+}
+
+// WriteSource reads the source, calls fn with its values and
+// writes them back using compare and swap. A source which has
+// nothing written yet starts empty and is written as is. It
+// returns ErrConflict if the source changed in the meantime.
+func WriteSource(w WritableSource, fn func(Values) error) (*ChangeSet, error) {
+	var format string
+
+	prev, err := w.Read()
+	switch {
+	case err == nil:
+		format = prev.Format
+	case err == ErrNotFound || os.IsNotExist(err):
+		prev = nil
+		if f, ok := w.(formatter); ok {
+			format = f.Format()
+		}
+	default:
+		return nil, err
+	}
+
+	// written back in the format of the source
+	if len(format) == 0 {
+		format = DefaultFormat
+	}
+
+	enc, ok := encoders()[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %s from %s", format, w.String())
+	}
+
+	data := make(map[string]interface{})
+	if prev != nil && len(prev.Data) > 0 {
+		if err := enc.Decode(prev.Data, &data); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	vals, err := newValues(&ChangeSet{Data: b})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(vals); err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(vals.Bytes(), &m); err != nil {
+		return nil, err
+	}
+
+	b, err = enc.Encode(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.Write(prev, b)
+}
+
+func (p *platform) Write(fn func(Values) error) error {
+	w := p.opts.Writer
+	if w == nil {
+		return errors.New("no writable source")
+	}
+
+	ch, err := WriteSource(w, fn)
+	if err != nil {
+		return err
+	}
+
+	// apply now rather than waiting for the watcher
+	for i, s := range p.opts.Sources {
+		if s == Source(w) {
+			p.apply(i, ch)
+		}
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@ func main() {
 		auth.Id("asim"),
 		auth.Secret("foobar"),
 	)
+	defer a.Close()
 
 	// retreive a token
 	t, err := a.Token()
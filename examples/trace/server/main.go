@@ -50,6 +50,7 @@ func main() {
 
 	server.DefaultServer = server.NewServer(
 		server.WrapHandler(trace.HandlerWrapper(t, srv)),
+		server.WrapSubscriber(trace.SubscriberWrapper(t, srv)),
 	)
 
 	// Initialise Server
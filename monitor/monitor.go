@@ -44,7 +44,8 @@ type Stats interface {
 	RecordStat(r Request, d time.Duration, err error)
 }
 
-// could be client or server request
+// could be client or server request. For
+// publications the service is the topic.
 type Request interface {
 	Service() string
 	Method() string
@@ -78,6 +79,12 @@ func HandlerWrapper(m Monitor) server.HandlerWrapper {
 	}
 }
 
+func SubscriberWrapper(m Monitor) server.SubscriberWrapper {
+	return func(fn server.SubscriberFunc) server.SubscriberFunc {
+		return subscriberWrapper(fn, m)
+	}
+}
+
 func NewMonitor(opts ...Option) Monitor {
 	return newPlatform(opts...)
 }
@@ -1,9 +1,12 @@
 package monitor
 
 import (
+	"io"
+	"sync"
+	"time"
+
 	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/server"
-	"time"
 
 	"golang.org/x/net/context"
 )
@@ -13,6 +16,61 @@ type clientWrapper struct {
 	m Monitor
 }
 
+// publication is a Request for broker publications.
+// The topic is the service and the method is empty.
+type publication struct {
+	topic string
+}
+
+// stream records the stat when the stream is closed,
+// ends or fails
+type stream struct {
+	client.Streamer
+	once sync.Once
+	fn   func(error)
+}
+
+func (p *publication) Service() string {
+	return p.topic
+}
+
+func (p *publication) Method() string {
+	return ""
+}
+
+// finish calls fn once however the stream ends
+func (s *stream) finish(err error) {
+	s.once.Do(func() {
+		s.fn(err)
+	})
+}
+
+func (s *stream) Send(v interface{}) error {
+	err := s.Streamer.Send(v)
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *stream) Recv(v interface{}) error {
+	err := s.Streamer.Recv(v)
+	switch err {
+	case nil:
+	case io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *stream) Close() error {
+	err := s.Streamer.Close()
+	s.finish(s.Streamer.Error())
+	return err
+}
+
 func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
 	t := time.Now()
 	err := c.Client.Call(ctx, req, rsp, opts...)
@@ -20,6 +78,28 @@ func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 	return err
 }
 
+func (c *clientWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Streamer, error) {
+	t := time.Now()
+	st, err := c.Client.Stream(ctx, req, opts...)
+	if err != nil {
+		c.m.RecordStat(req, time.Since(t), err)
+		return nil, err
+	}
+	return &stream{
+		Streamer: st,
+		fn: func(err error) {
+			c.m.RecordStat(req, time.Since(t), err)
+		},
+	}, nil
+}
+
+func (c *clientWrapper) Publish(ctx context.Context, p client.Publication, opts ...client.PublishOption) error {
+	t := time.Now()
+	err := c.Client.Publish(ctx, p, opts...)
+	c.m.RecordStat(&publication{p.Topic()}, time.Since(t), err)
+	return err
+}
+
 func handlerWrapper(fn server.HandlerFunc, m Monitor) server.HandlerFunc {
 	return func(ctx context.Context, req server.Request, rsp interface{}) error {
 		t := time.Now()
@@ -28,3 +108,12 @@ func handlerWrapper(fn server.HandlerFunc, m Monitor) server.HandlerFunc {
 		return err
 	}
 }
+
+func subscriberWrapper(fn server.SubscriberFunc, m Monitor) server.SubscriberFunc {
+	return func(ctx context.Context, msg server.Publication) error {
+		t := time.Now()
+		err := fn(ctx, msg)
+		m.RecordStat(&publication{msg.Topic()}, time.Since(t), err)
+		return err
+	}
+}
@@ -0,0 +1,219 @@
+package monitor
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/server"
+
+	"golang.org/x/net/context"
+)
+
+type testStat struct {
+	service string
+	method  string
+	err     error
+}
+
+// testMonitor records stats in memory
+type testMonitor struct {
+	Monitor
+
+	sync.Mutex
+	stats []testStat
+}
+
+type testStream struct {
+	client.Streamer
+	err error
+}
+
+type testClient struct {
+	client.Client
+	err    error
+	stream *testStream
+}
+
+type testRequest struct{}
+
+type testPublication struct {
+	topic string
+}
+
+func (m *testMonitor) RecordStat(r Request, d time.Duration, err error) {
+	m.Lock()
+	m.stats = append(m.stats, testStat{r.Service(), r.Method(), err})
+	m.Unlock()
+}
+
+func (m *testMonitor) recorded() []testStat {
+	m.Lock()
+	defer m.Unlock()
+	return m.stats
+}
+
+func (s *testStream) Send(v interface{}) error {
+	return s.err
+}
+
+func (s *testStream) Recv(v interface{}) error {
+	return s.err
+}
+
+func (s *testStream) Error() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+func (s *testStream) Close() error {
+	return nil
+}
+
+func (c *testClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Streamer, error) {
+	return c.stream, c.err
+}
+
+func (c *testClient) Publish(ctx context.Context, p client.Publication, opts ...client.PublishOption) error {
+	return c.err
+}
+
+func (r *testRequest) Service() string {
+	return "go.micro.srv.foo"
+}
+
+func (r *testRequest) Method() string {
+	return "Foo.Bar"
+}
+
+func (r *testRequest) ContentType() string {
+	return "application/json"
+}
+
+func (r *testRequest) Request() interface{} {
+	return nil
+}
+
+func (r *testRequest) Stream() bool {
+	return true
+}
+
+func (p *testPublication) Topic() string {
+	return p.topic
+}
+
+func (p *testPublication) Message() interface{} {
+	return nil
+}
+
+func (p *testPublication) ContentType() string {
+	return "application/json"
+}
+
+func TestStream(t *testing.T) {
+	testErr := errors.New("stream failed")
+
+	testData := []struct {
+		name string
+		err  error
+		// calls on the stream before it's closed
+		fn  func(client.Streamer)
+		exp error
+	}{
+		{"eof", io.EOF, func(s client.Streamer) { s.Recv(nil) }, nil},
+		{"recv error", testErr, func(s client.Streamer) { s.Recv(nil) }, testErr},
+		{"send error", testErr, func(s client.Streamer) { s.Send(nil) }, testErr},
+		{"close", nil, func(s client.Streamer) {}, nil},
+	}
+
+	for _, d := range testData {
+		m := &testMonitor{}
+		c := ClientWrapper(m)(&testClient{stream: &testStream{err: d.err}})
+
+		st, err := c.Stream(context.TODO(), &testRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := len(m.recorded()); n != 0 {
+			t.Fatalf("%s: Expected no stat before the stream ends got %d", d.name, n)
+		}
+
+		d.fn(st)
+		d.fn(st)
+		st.Close()
+		st.Close()
+
+		stats := m.recorded()
+		if len(stats) != 1 {
+			t.Fatalf("%s: Expected 1 stat got %d", d.name, len(stats))
+		}
+
+		if s := stats[0]; s.service != "go.micro.srv.foo" || s.method != "Foo.Bar" || s.err != d.exp {
+			t.Fatalf("%s: Unexpected stat %+v", d.name, s)
+		}
+	}
+
+	// failing to open the stream
+	m := &testMonitor{}
+	c := ClientWrapper(m)(&testClient{err: testErr})
+
+	if _, err := c.Stream(context.TODO(), &testRequest{}); err != testErr {
+		t.Fatalf("Expected %v got %v", testErr, err)
+	}
+
+	if stats := m.recorded(); len(stats) != 1 || stats[0].err != testErr {
+		t.Fatalf("Expected 1 failed stat got %+v", stats)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	testErr := errors.New("publish failed")
+
+	for _, err := range []error{nil, testErr} {
+		m := &testMonitor{}
+		c := ClientWrapper(m)(&testClient{err: err})
+
+		if e := c.Publish(context.TODO(), &testPublication{"topic.foo"}); e != err {
+			t.Fatalf("Expected %v got %v", err, e)
+		}
+
+		// the topic is the service
+		stats := m.recorded()
+		if len(stats) != 1 || stats[0] != (testStat{"topic.foo", "", err}) {
+			t.Fatalf("Unexpected stats %+v", stats)
+		}
+	}
+}
+
+func TestSubscriberWrapper(t *testing.T) {
+	testErr := errors.New("subscriber failed")
+
+	for _, err := range []error{nil, testErr} {
+		m := &testMonitor{}
+
+		var called bool
+		fn := SubscriberWrapper(m)(func(ctx context.Context, msg server.Publication) error {
+			called = true
+			return err
+		})
+
+		if e := fn(context.TODO(), &testPublication{"topic.foo"}); e != err {
+			t.Fatalf("Expected %v got %v", err, e)
+		}
+
+		if !called {
+			t.Fatal("Expected subscriber to be called")
+		}
+
+		stats := m.recorded()
+		if len(stats) != 1 || stats[0] != (testStat{"topic.foo", "", err}) {
+			t.Fatalf("Unexpected stats %+v", stats)
+		}
+	}
+}
@@ -95,6 +95,12 @@ func HandlerWrapper(t Trace, s *registry.Service) server.HandlerWrapper {
 	}
 }
 
+func SubscriberWrapper(t Trace, s *registry.Service) server.SubscriberWrapper {
+	return func(fn server.SubscriberFunc) server.SubscriberFunc {
+		return subscriberWrapper(fn, t, s)
+	}
+}
+
 func NewTrace(opts ...Option) Trace {
 	return newPlatform(opts...)
 }
@@ -1,11 +1,14 @@
 package trace
 
 import (
+	"io"
+	"sync"
+	"time"
+
 	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/metadata"
 	"github.com/micro/go-micro/registry"
 	"github.com/micro/go-micro/server"
-	"time"
 
 	"golang.org/x/net/context"
 )
@@ -16,10 +19,52 @@ type clientWrapper struct {
 	s *registry.Service
 }
 
-func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+// stream finishes the span when the stream is closed,
+// ends or fails
+type stream struct {
+	client.Streamer
+	once sync.Once
+	fn   func(error)
+}
+
+// finish calls fn once however the stream ends
+func (s *stream) finish(err error) {
+	s.once.Do(func() {
+		s.fn(err)
+	})
+}
+
+func (s *stream) Send(v interface{}) error {
+	err := s.Streamer.Send(v)
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *stream) Recv(v interface{}) error {
+	err := s.Streamer.Recv(v)
+	switch err {
+	case nil:
+	case io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *stream) Close() error {
+	err := s.Streamer.Close()
+	s.finish(s.Streamer.Error())
+	return err
+}
+
+// newSpan creates a span for the given name, a child of any
+// span found in the context, and a context which carries it.
+func (c *clientWrapper) newSpan(ctx context.Context, name, service string) (*Span, context.Context) {
 	var span *Span
 	var ok, okk bool
-	var err error
 
 	md, mk := metadata.FromContext(ctx)
 
@@ -64,15 +109,49 @@ func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 	// and mark as debug? might want to do this based on a setting
 	span.Debug = true
 	// set uniq span name
-	span.Name = req.Service() + "." + req.Method()
+	span.Name = name
 	// set source/dest
 	span.Source = c.s
-	span.Destination = &registry.Service{Name: req.Service()}
+	span.Destination = &registry.Service{Name: service}
+
+	// copy the metadata so we don't modify the callers
+	nmd := metadata.Metadata{}
+	for k, v := range md {
+		nmd[k] = v
+	}
 
 	// set context key
 	newCtx := c.t.NewContext(ctx, span)
 	// set metadata
-	newCtx = metadata.NewContext(newCtx, c.t.NewHeader(md, span))
+	newCtx = metadata.NewContext(newCtx, c.t.NewHeader(nmd, span))
+
+	return span, newCtx
+}
+
+// end marks the end of the span and flushes it to the collector
+func (c *clientWrapper) end(span *Span, err error) {
+	var debug map[string]string
+	if err != nil {
+		debug = map[string]string{"error": err.Error()}
+	}
+	// mark end of span
+	span.Annotations = append(span.Annotations, &Annotation{
+		Timestamp: time.Now(),
+		Type:      AnnEnd,
+		Service:   c.s,
+		Debug:     debug,
+	})
+
+	span.Duration = time.Now().Sub(span.Timestamp)
+
+	// flush the span to the collector on return
+	c.t.Collect(span)
+}
+
+func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	var err error
+
+	span, newCtx := c.newSpan(ctx, req.Service()+"."+req.Method(), req.Service())
 
 	// mark client request
 	span.Annotations = append(span.Annotations, &Annotation{
@@ -90,51 +169,89 @@ func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 			Service:   c.s,
 		})
 
-		// if we were the creator
-		var debug map[string]string
-		if err != nil {
-			debug = map[string]string{"error": err.Error()}
-		}
-		// mark end of span
+		c.end(span, err)
+	}()
+
+	// now just make a regular call down the stack
+	err = c.Client.Call(newCtx, req, rsp, opts...)
+	return err
+}
+
+func (c *clientWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Streamer, error) {
+	span, newCtx := c.newSpan(ctx, req.Service()+"."+req.Method(), req.Service())
+
+	// mark client request
+	span.Annotations = append(span.Annotations, &Annotation{
+		Timestamp: time.Now(),
+		Type:      AnnClientRequest,
+		Service:   c.s,
+	})
+
+	finish := func(err error) {
+		// mark client response
 		span.Annotations = append(span.Annotations, &Annotation{
 			Timestamp: time.Now(),
-			Type:      AnnEnd,
+			Type:      AnnClientResponse,
 			Service:   c.s,
-			Debug:     debug,
 		})
 
-		span.Duration = time.Now().Sub(span.Timestamp)
+		c.end(span, err)
+	}
 
-		// flush the span to the collector on return
-		c.t.Collect(span)
-	}()
+	st, err := c.Client.Stream(newCtx, req, opts...)
+	if err != nil {
+		finish(err)
+		return nil, err
+	}
 
-	// now just make a regular call down the stack
-	err = c.Client.Call(newCtx, req, rsp, opts...)
+	// the span completes when the stream is closed or ends
+	return &stream{Streamer: st, fn: finish}, nil
+}
+
+func (c *clientWrapper) Publish(ctx context.Context, p client.Publication, opts ...client.PublishOption) error {
+	span, newCtx := c.newSpan(ctx, p.Topic(), p.Topic())
+
+	// mark client publication
+	span.Annotations = append(span.Annotations, &Annotation{
+		Timestamp: time.Now(),
+		Type:      AnnClientPublication,
+		Service:   c.s,
+	})
+
+	err := c.Client.Publish(newCtx, p, opts...)
+	c.end(span, err)
 	return err
 }
 
+// serverSpan gets the span from the metadata or creates a new one
+func serverSpan(ctx context.Context, t Trace) *Span {
+	var span *Span
+
+	// get trace info from metadata
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		// this is a new span
+		span = t.NewSpan(nil)
+	} else {
+		// can we gt the span from the header?
+		span, ok = t.FromHeader(md)
+		if !ok {
+			// no, ok create one
+			span = t.NewSpan(nil)
+		}
+	}
+
+	return span
+}
+
 func handlerWrapper(fn server.HandlerFunc, t Trace, s *registry.Service) server.HandlerFunc {
 	return func(ctx context.Context, req server.Request, rsp interface{}) error {
 		// embed trace instance
 		newCtx := NewContext(ctx, t)
 
-		var span *Span
 		var err error
 
-		// get trace info from metadata
-		md, ok := metadata.FromContext(ctx)
-		if !ok {
-			// this is a new span
-			span = t.NewSpan(nil)
-		} else {
-			// can we gt the span from the header?
-			span, ok = t.FromHeader(md)
-			if !ok {
-				// no, ok create one
-				span = t.NewSpan(nil)
-			}
-		}
+		span := serverSpan(ctx, t)
 
 		// mark client request
 		span.Annotations = append(span.Annotations, &Annotation{
@@ -177,3 +294,52 @@ func handlerWrapper(fn server.HandlerFunc, t Trace, s *registry.Service) server.
 		return err
 	}
 }
+
+func subscriberWrapper(fn server.SubscriberFunc, t Trace, s *registry.Service) server.SubscriberFunc {
+	return func(ctx context.Context, msg server.Publication) error {
+		// embed trace instance
+		newCtx := NewContext(ctx, t)
+
+		var err error
+
+		span := serverSpan(ctx, t)
+
+		// mark server subscription
+		span.Annotations = append(span.Annotations, &Annotation{
+			Timestamp: time.Now(),
+			Type:      AnnServerSubscription,
+			Service:   s,
+		})
+
+		span.Debug = true
+		// the topic is the span name
+		span.Name = msg.Topic()
+		span.Source = s
+		span.Destination = s
+
+		// embed the span in the context
+		newCtx = t.NewContext(newCtx, span)
+
+		defer func() {
+			var debug map[string]string
+			if err != nil {
+				debug = map[string]string{"error": err.Error()}
+			}
+			// mark end of processing
+			span.Annotations = append(span.Annotations, &Annotation{
+				Timestamp: time.Now(),
+				Type:      AnnEnd,
+				Service:   s,
+				Debug:     debug,
+			})
+
+			span.Duration = time.Now().Sub(span.Timestamp)
+
+			// flush the span to the collector on return
+			t.Collect(span)
+		}()
+
+		err = fn(newCtx, msg)
+		return err
+	}
+}
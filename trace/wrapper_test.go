@@ -0,0 +1,282 @@
+package trace
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/server"
+
+	"golang.org/x/net/context"
+)
+
+// testTrace collects spans in memory
+type testTrace struct {
+	Trace
+
+	sync.Mutex
+	spans []*Span
+}
+
+type testStream struct {
+	client.Streamer
+	err error
+}
+
+type testClient struct {
+	client.Client
+	err    error
+	stream *testStream
+}
+
+type testRequest struct{}
+
+type testPublication struct {
+	topic string
+}
+
+func (t *testTrace) NewSpan(s *Span) *Span {
+	if s == nil {
+		s = &Span{}
+	}
+	s.Timestamp = time.Now()
+	return s
+}
+
+func (t *testTrace) NewContext(ctx context.Context, s *Span) context.Context {
+	return ctx
+}
+
+func (t *testTrace) FromContext(ctx context.Context) (*Span, bool) {
+	return nil, false
+}
+
+func (t *testTrace) NewHeader(md map[string]string, s *Span) map[string]string {
+	return md
+}
+
+func (t *testTrace) FromHeader(md map[string]string) (*Span, bool) {
+	return nil, false
+}
+
+func (t *testTrace) Collect(s *Span) error {
+	t.Lock()
+	t.spans = append(t.spans, s)
+	t.Unlock()
+	return nil
+}
+
+func (t *testTrace) collected() []*Span {
+	t.Lock()
+	defer t.Unlock()
+	return t.spans
+}
+
+func (s *testStream) Send(v interface{}) error {
+	return s.err
+}
+
+func (s *testStream) Recv(v interface{}) error {
+	return s.err
+}
+
+func (s *testStream) Error() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+func (s *testStream) Close() error {
+	return nil
+}
+
+func (c *testClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Streamer, error) {
+	return c.stream, c.err
+}
+
+func (c *testClient) Publish(ctx context.Context, p client.Publication, opts ...client.PublishOption) error {
+	return c.err
+}
+
+func (r *testRequest) Service() string {
+	return "go.micro.srv.foo"
+}
+
+func (r *testRequest) Method() string {
+	return "Foo.Bar"
+}
+
+func (r *testRequest) ContentType() string {
+	return "application/json"
+}
+
+func (r *testRequest) Request() interface{} {
+	return nil
+}
+
+func (r *testRequest) Stream() bool {
+	return true
+}
+
+func (p *testPublication) Topic() string {
+	return p.topic
+}
+
+func (p *testPublication) Message() interface{} {
+	return nil
+}
+
+func (p *testPublication) ContentType() string {
+	return "application/json"
+}
+
+// spanError returns the error recorded at the end of the span
+func spanError(s *Span) string {
+	for _, a := range s.Annotations {
+		if a.Type == AnnEnd && a.Debug != nil {
+			return a.Debug["error"]
+		}
+	}
+	return ""
+}
+
+func TestStream(t *testing.T) {
+	testErr := errors.New("stream failed")
+
+	testData := []struct {
+		name string
+		err  error
+		// calls on the stream before it's closed
+		fn  func(client.Streamer)
+		exp string
+	}{
+		{"eof", io.EOF, func(s client.Streamer) { s.Recv(nil) }, ""},
+		{"recv error", testErr, func(s client.Streamer) { s.Recv(nil) }, testErr.Error()},
+		{"send error", testErr, func(s client.Streamer) { s.Send(nil) }, testErr.Error()},
+		{"close", nil, func(s client.Streamer) {}, ""},
+	}
+
+	for _, d := range testData {
+		tr := &testTrace{}
+		c := ClientWrapper(tr, nil)(&testClient{stream: &testStream{err: d.err}})
+
+		st, err := c.Stream(context.TODO(), &testRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := len(tr.collected()); n != 0 {
+			t.Fatalf("%s: Expected no span before the stream ends got %d", d.name, n)
+		}
+
+		d.fn(st)
+		d.fn(st)
+		st.Close()
+		st.Close()
+
+		spans := tr.collected()
+		if len(spans) != 1 {
+			t.Fatalf("%s: Expected 1 span got %d", d.name, len(spans))
+		}
+
+		if s := spanError(spans[0]); s != d.exp {
+			t.Fatalf("%s: Expected error %q got %q", d.name, d.exp, s)
+		}
+
+		if spans[0].Name != "go.micro.srv.foo.Foo.Bar" {
+			t.Fatalf("%s: Expected go.micro.srv.foo.Foo.Bar got %s", d.name, spans[0].Name)
+		}
+	}
+
+	// failing to open the stream
+	tr := &testTrace{}
+	c := ClientWrapper(tr, nil)(&testClient{err: testErr})
+
+	if _, err := c.Stream(context.TODO(), &testRequest{}); err != testErr {
+		t.Fatalf("Expected %v got %v", testErr, err)
+	}
+
+	if spans := tr.collected(); len(spans) != 1 || spanError(spans[0]) != testErr.Error() {
+		t.Fatalf("Expected 1 failed span got %+v", spans)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	testErr := errors.New("publish failed")
+
+	for _, err := range []error{nil, testErr} {
+		tr := &testTrace{}
+		c := ClientWrapper(tr, nil)(&testClient{err: err})
+
+		if e := c.Publish(context.TODO(), &testPublication{"topic.foo"}); e != err {
+			t.Fatalf("Expected %v got %v", err, e)
+		}
+
+		spans := tr.collected()
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 span got %d", len(spans))
+		}
+
+		if spans[0].Name != "topic.foo" {
+			t.Fatalf("Expected topic.foo got %s", spans[0].Name)
+		}
+
+		var exp string
+		if err != nil {
+			exp = err.Error()
+		}
+		if s := spanError(spans[0]); s != exp {
+			t.Fatalf("Expected error %q got %q", exp, s)
+		}
+	}
+}
+
+func TestSubscriberWrapper(t *testing.T) {
+	testErr := errors.New("subscriber failed")
+	s := &registry.Service{Name: "go.micro.srv.foo"}
+
+	for _, err := range []error{nil, testErr} {
+		tr := &testTrace{}
+
+		var called bool
+		fn := SubscriberWrapper(tr, s)(func(ctx context.Context, msg server.Publication) error {
+			called = true
+			return err
+		})
+
+		if e := fn(context.TODO(), &testPublication{"topic.foo"}); e != err {
+			t.Fatalf("Expected %v got %v", err, e)
+		}
+
+		if !called {
+			t.Fatal("Expected subscriber to be called")
+		}
+
+		spans := tr.collected()
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 span got %d", len(spans))
+		}
+
+		span := spans[0]
+		if span.Name != "topic.foo" || span.Destination != s {
+			t.Fatalf("Unexpected span %+v", span)
+		}
+
+		if a := span.Annotations; len(a) != 2 || a[0].Type != AnnServerSubscription || a[1].Type != AnnEnd {
+			t.Fatalf("Unexpected annotations %+v", a)
+		}
+
+		var exp string
+		if err != nil {
+			exp = err.Error()
+		}
+		if e := spanError(span); e != exp {
+			t.Fatalf("Expected error %q got %q", exp, e)
+		}
+	}
+}